@@ -0,0 +1,76 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui"
+)
+
+var (
+	hpaOpts = &metrics.HPAOptions{}
+	hpaCmd  = &cobra.Command{
+		Use:     "hpa",
+		Aliases: []string{"hpas", "horizontalpodautoscaler", "horizontalpodautoscalers"},
+		Short:   "Show horizontal pod autoscalers",
+		Long: addKeyboardShortcutsToDescription(`Show horizontal pod autoscalers.
+
+The TARGETS column has the current and target value of every metric an
+autoscaler scales on as the controller last saw them. The graphs show the
+desired and current replicas and the target and current value of the first
+metric over time.
+
+Autoscalers that are unable to scale are shown in red and the ones held at
+their min or max replicas are shown in yellow.
+
+Pressing p opens the pod view for the pods of the selected autoscaler's
+target, the --source flags apply to it.`),
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := validateCommonFlags(); err != nil {
+				return err
+			}
+			if err := validateSource(); err != nil {
+				return err
+			}
+			if err := validateCustomMetrics(); err != nil {
+				return err
+			}
+			// the pod view opened from an autoscaler is priced
+			if err := metrics.ValidatePricing(config.GetPricing()); err != nil {
+				return err
+			}
+			app, err := ui.New(metrics.HPA, hpaOpts, settings, flags)
+			if err != nil {
+				return err
+			}
+			_, err = tea.NewProgram(app, tea.WithAltScreen()).Run()
+			return err
+		},
+	}
+)
+
+func init() {
+	hpaCmd.Flags().StringVarP(&hpaOpts.LabelSelector, "selector", "l", hpaOpts.LabelSelector, selectorHelpStr)
+	hpaCmd.Flags().BoolVarP(&hpaOpts.AllNamespaces, "all-namespaces", "A", false, allNsHelpStr)
+	addCommonFlags(hpaCmd)
+	addSourceFlags(hpaCmd)
+	rootCmd.AddCommand(hpaCmd)
+}
@@ -21,6 +21,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/chriskim06/kubectl-topui/internal/metrics"
 	"github.com/chriskim06/kubectl-topui/internal/ui"
+	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/kubectl/pkg/cmd/top"
@@ -38,10 +39,40 @@ var (
 		Use:     "node",
 		Aliases: []string{"nodes"},
 		Short:   "Show node metrics",
-		Long:    addKeyboardShortcutsToDescription("Show various widgets for node metrics."),
-		RunE: func(_ *cobra.Command, args []string) error {
-			app := ui.New(metrics.NODE, interval, nodeOpts, showManagedFields, flags)
-			_, err := tea.NewProgram(app, tea.WithAltScreen()).Run()
+		Long: addKeyboardShortcutsToDescription(`Show various widgets for node metrics.
+
+Nodes that are not ready or have a pressure condition are shown in red and
+cordoned nodes are shown in yellow.
+
+Draining a node is simulated by taking the pods running on it (excluding
+DaemonSet and static pods) and fitting their requests into the free allocatable
+of the remaining schedulable nodes. Nothing is evicted.
+
+The usage of the pods on the selected node is read from its kubelet summary
+api (needs get on nodes/proxy) on every refresh from when it is selected.
+Pressing n ranks them by their share of the node's usage and by how much they
+grew over the last five minutes. The pods that make up most of the node's growth are shown in red.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateCommonFlags(); err != nil {
+				return err
+			}
+			if err := utils.ValidateColumns(metrics.NODE, settings.Columns); err != nil {
+				return err
+			}
+			if err := validateSource(); err != nil {
+				return err
+			}
+			if err := validateCustomMetrics(); err != nil {
+				return err
+			}
+			if err := setAnomalySensitivity(cmd); err != nil {
+				return err
+			}
+			app, err := ui.New(metrics.NODE, nodeOpts, settings, flags)
+			if err != nil {
+				return err
+			}
+			_, err = tea.NewProgram(app, tea.WithAltScreen()).Run()
 			return err
 		},
 	}
@@ -49,9 +80,10 @@ var (
 
 func init() {
 	nodeCmd.Flags().StringVarP(&nodeOpts.Selector, "selector", "l", nodeOpts.Selector, selectorHelpStr)
-	nodeCmd.Flags().IntVar(&interval, "interval", 3, intervalHelpStr)
 	nodeCmd.Flags().StringVar(&nodeOpts.SortBy, "sort-by", nodeOpts.SortBy, "If non-empty, sort nodes list using specified field. The field can be either 'cpu' or 'memory'.")
-	nodeCmd.Flags().BoolVarP(&showManagedFields, "show-managed-fields", "m", false, showManagedFieldsHelpStr)
-	flags.AddFlags(nodeCmd.Flags())
+	nodeCmd.Flags().StringSliceVar(&settings.Columns, "columns", settings.Columns, columnsHelpStr(metrics.NODE))
+	addAnomalyFlag(nodeCmd)
+	addCommonFlags(nodeCmd)
+	addSourceFlags(nodeCmd)
 	rootCmd.AddCommand(nodeCmd)
 }
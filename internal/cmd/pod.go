@@ -23,8 +23,10 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/kubectl/pkg/cmd/top"
 
+	"github.com/chriskim06/kubectl-topui/internal/config"
 	"github.com/chriskim06/kubectl-topui/internal/metrics"
 	"github.com/chriskim06/kubectl-topui/internal/ui"
+	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
 )
 
 var (
@@ -42,11 +44,42 @@ var (
 		Long: addKeyboardShortcutsToDescription(`Show pod metrics.
 
 CPU and memory percentages are calculated by getting the sum of the container
-limits for a given pod.`),
+limits for a given pod.
+
+The STATUS column is computed the same way kubectl get pods does and the LAST
+TERMINATION column shows the most recent container termination reason.
+
+A pod can stay well under its cpu limit on average and still be throttled
+for most of its periods, the throttling column and --throttling show how
+often that happens for the pods on the node of the selected pod.
+
+With pricing in the config file the cost columns estimate what each pod
+costs by its requests and by its usage, the status bar has the total.`),
 		Args: cobra.NoArgs,
-		RunE: func(_ *cobra.Command, args []string) error {
-			app := ui.New(metrics.POD, interval, podOpts, showManagedFields, flags)
-			_, err := tea.NewProgram(app, tea.WithAltScreen()).Run()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateCommonFlags(); err != nil {
+				return err
+			}
+			if err := utils.ValidateColumns(metrics.POD, settings.Columns); err != nil {
+				return err
+			}
+			if err := validateSource(); err != nil {
+				return err
+			}
+			if err := validateCustomMetrics(); err != nil {
+				return err
+			}
+			if err := metrics.ValidatePricing(config.GetPricing()); err != nil {
+				return err
+			}
+			if err := setAnomalySensitivity(cmd); err != nil {
+				return err
+			}
+			app, err := ui.New(metrics.POD, podOpts, settings, flags)
+			if err != nil {
+				return err
+			}
+			_, err = tea.NewProgram(app, tea.WithAltScreen()).Run()
 			return err
 		},
 	}
@@ -54,10 +87,12 @@ limits for a given pod.`),
 
 func init() {
 	podCmd.Flags().StringVarP(&podOpts.LabelSelector, "selector", "l", podOpts.LabelSelector, selectorHelpStr)
+	podCmd.Flags().StringSliceVar(&settings.Columns, "columns", settings.Columns, columnsHelpStr(metrics.POD))
 	podCmd.Flags().BoolVarP(&podOpts.AllNamespaces, "all-namespaces", "A", false, allNsHelpStr)
-	podCmd.Flags().IntVar(&interval, "interval", 3, intervalHelpStr)
 	podCmd.Flags().StringVar(&podOpts.SortBy, "sort-by", podOpts.SortBy, "If non-empty, sort pods list using specified field. The field can be either 'cpu' or 'memory'.")
-	podCmd.Flags().BoolVarP(&showManagedFields, "show-managed-fields", "m", false, showManagedFieldsHelpStr)
-	flags.AddFlags(podCmd.Flags())
+	podCmd.Flags().BoolVar(&settings.Throttling, "throttling", settings.Throttling, throttlingHelpStr)
+	addAnomalyFlag(podCmd)
+	addCommonFlags(podCmd)
+	addSourceFlags(podCmd)
 	rootCmd.AddCommand(podCmd)
 }
@@ -0,0 +1,61 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui"
+)
+
+var (
+	pvcOpts = &metrics.PVCOptions{}
+	pvcCmd  = &cobra.Command{
+		Use:     "pvc",
+		Aliases: []string{"pvcs", "persistentvolumeclaim", "persistentvolumeclaims"},
+		Short:   "Show persistent volume claim usage",
+		Long: addKeyboardShortcutsToDescription(`Show persistent volume claim usage.
+
+The capacity, used bytes and inodes come from the volume stats in the kubelet
+summary api of the nodes running the pods that mount each claim, read through
+the api server proxy (needs get on nodes/proxy). Claims that aren't mounted
+only show their requested capacity.
+
+Claims that are at least 90% full by bytes or inodes are shown in red and the
+ones at least 80% full are shown in yellow.`),
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := validateCommonFlags(); err != nil {
+				return err
+			}
+			app, err := ui.New(metrics.PVC, pvcOpts, settings, flags)
+			if err != nil {
+				return err
+			}
+			_, err = tea.NewProgram(app, tea.WithAltScreen()).Run()
+			return err
+		},
+	}
+)
+
+func init() {
+	pvcCmd.Flags().StringVarP(&pvcOpts.LabelSelector, "selector", "l", pvcOpts.LabelSelector, selectorHelpStr)
+	pvcCmd.Flags().BoolVarP(&pvcOpts.AllNamespaces, "all-namespaces", "A", false, allNsHelpStr)
+	addCommonFlags(pvcCmd)
+	rootCmd.AddCommand(pvcCmd)
+}
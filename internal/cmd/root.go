@@ -19,17 +19,29 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
 var (
-	flags             = genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag()
-	interval          = 3
-	showManagedFields = false
-	rootCmd           = &cobra.Command{
+	flags    = genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag()
+	settings = ui.Settings{
+		Interval:    3,
+		Timeout:     10 * time.Second,
+		ChunkSize:   500,
+		MaxRows:     1000,
+		GracePeriod: 5 * time.Minute,
+		Source:      metrics.MetricsServerSource,
+
+		AnomalySensitivity: 3,
+	}
+	rootCmd = &cobra.Command{
 		Use:   "topui",
 		Short: "Prettier kubectl top output",
 		Long: addKeyboardShortcutsToDescription(`Render kubectl top output with fancier widgets!
@@ -45,11 +57,16 @@ theme:
   cpuUsage: color
   memLimit: color
   memUsage: color
+  cpuThrottled: color
+  anomaly: color
 
 The color can be a lowercased color name corresponding to ANSI colors.`),
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		Args:          cobra.NoArgs,
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			return config.Load()
+		},
 	}
 )
 
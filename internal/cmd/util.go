@@ -17,21 +17,118 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
+	"github.com/spf13/cobra"
 )
 
 const (
 	selectorHelpStr          = "Selector (label query) to filter on, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)."
-	intervalHelpStr          = "The interval in seconds between getting metrics (defaults to 3)."
-	showManagedFieldsHelpStr = "Display managed fields when viewing pod or node manifests."
+	intervalHelpStr          = "The minimum interval in seconds between getting metrics (defaults to 3). It grows to half of how often metrics-server takes new samples."
+	wasteIntervalHelpStr     = "The interval in seconds between getting metrics while the usage is watched, or the step of the history from prometheus."
+	timeoutHelpStr           = "The timeout for each call to the api server, the ui keeps responding while calls are in flight."
+	chunkSizeHelpStr         = "Return large lists of metrics, quotas and the pods and nodes for a drain in chunks rather than all at once. The watched pods, nodes, claims and autoscalers are listed by informers and are not chunked. Pass 0 to disable."
+	maxRowsHelpStr           = "The maximum number of rows to render, the rest are summarized at the bottom of the list. Pass 0 to render every row."
+	gracePeriodHelpStr       = "How long objects that no longer exist are still shown as dimmed rows before their history is dropped."
+	sourceHelpStr            = "Where the metrics come from, one of metrics-server, kubelet or prometheus. The kubelet source reads /metrics/resource of each node through the api server proxy (needs get on nodes/proxy) and computes cpu rates between the readings the kubelet takes at its housekeeping interval, new pods and nodes show as warming up until it has taken two. The prometheus source runs the queries from the config file and fills the graphs with the history prometheus already has."
+	statsHelpStr             = "Read network and filesystem usage from the kubelet summary api through the api server proxy (needs get on nodes/proxy). Turned on by the network, ephemeral and rootfs columns."
+	throttlingHelpStr        = "Read the cfs throttling of the pods from the cadvisor metrics of their nodes through the api server proxy (needs get on nodes/proxy) and draw it on the cpu graph, reaching the limit line means every period was throttled. Only the node of the selected pod is read so the pods sharing it get throttling. Turned on by the throttling column."
+	anomalyHelpStr           = "How many standard deviations from its moving average the cpu or memory usage of a pod or node has to be for it to be flagged and marked on the graphs. Pass 0 to turn it off. Overrides anomalies.sensitivity in the config file."
+	prometheusURLHelpStr     = "The url of the prometheus compatible api used by --source=prometheus, overrides prometheus.url in the config file."
+	showManagedFieldsHelpStr = "Display managed fields when viewing manifests."
 	allNsHelpStr             = "If present, list the requested object(s) across all namespaces. Namespace in current context is ignored even if specified with --namespace."
 	keyboardShortcuts        = `
 Keyboard Shortcuts:
   - q: quit
   - j: scroll down
   - k: scroll up
-  - enter: view spec for selected item`
+  - enter: view spec for selected item
+  - d: simulate draining the selected node
+  - p: show the pods scaled by the selected hpa, q goes back
+  - c: show the estimated cost by namespace and workload (when pricing is configured)
+  - t: show min, max, mean, percentiles and rate of change of the selected item's graphs
+  - n: rank the pods on the selected node by their share of its usage and their growth
+  - w: show the pods, workloads and namespaces using the least of their requests
+  - r: show resource quota usage and limit range defaults of the namespace
+  - s: switch the graphs between cpu/memory, network/storage (with --stats) and custom metrics`
 )
 
+// addCommonFlags adds the flags shared by every command
+func addCommonFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&settings.Interval, "interval", settings.Interval, intervalHelpStr)
+	cmd.Flags().DurationVar(&settings.Timeout, "timeout", settings.Timeout, timeoutHelpStr)
+	cmd.Flags().Int64Var(&settings.ChunkSize, "chunk-size", settings.ChunkSize, chunkSizeHelpStr)
+	cmd.Flags().IntVar(&settings.MaxRows, "max-rows", settings.MaxRows, maxRowsHelpStr)
+	cmd.Flags().DurationVar(&settings.GracePeriod, "gone-grace-period", settings.GracePeriod, gracePeriodHelpStr)
+	cmd.Flags().BoolVarP(&settings.ShowManagedFields, "show-managed-fields", "m", false, showManagedFieldsHelpStr)
+	flags.AddFlags(cmd.Flags())
+}
+
+// addSourceFlags adds the flags choosing where the pod and node metrics
+// come from
+func addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&settings.Source, "source", settings.Source, sourceHelpStr)
+	cmd.Flags().BoolVar(&settings.Stats, "stats", settings.Stats, statsHelpStr)
+	cmd.Flags().StringVar(&settings.PrometheusURL, "prometheus-url", settings.PrometheusURL, prometheusURLHelpStr)
+}
+
+// addAnomalyFlag adds the flag for how unusual usage has to be to be
+// flagged
+func addAnomalyFlag(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&settings.AnomalySensitivity, "anomaly-sensitivity", settings.AnomalySensitivity, anomalyHelpStr)
+}
+
+// setAnomalySensitivity uses the config file when the flag isn't set
+func setAnomalySensitivity(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("anomaly-sensitivity") {
+		settings.AnomalySensitivity = config.GetAnomalies().Sensitivity
+	}
+	if settings.AnomalySensitivity < 0 {
+		return fmt.Errorf("invalid anomaly sensitivity %v, must not be negative", settings.AnomalySensitivity)
+	}
+	return nil
+}
+
+// validateSource checks the source flag and the config of the prometheus
+// source when it is used
+func validateSource() error {
+	if err := metrics.ValidateSource(settings.Source); err != nil {
+		return err
+	}
+	if settings.Source == metrics.PrometheusSource {
+		return metrics.ValidatePrometheus(config.GetPrometheus())
+	}
+	return nil
+}
+
+// validateCommonFlags checks the flags added by addCommonFlags, a zero
+// timeout fails every call and a zero interval never waits between them
+func validateCommonFlags() error {
+	if settings.Interval <= 0 {
+		return fmt.Errorf("invalid interval %d, must be positive", settings.Interval)
+	}
+	if settings.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout %s, must be positive", settings.Timeout)
+	}
+	return nil
+}
+
+// validateCustomMetrics checks the custom metrics from the config file
+// against the built in columns they are shown next to
+func validateCustomMetrics() error {
+	return metrics.ValidateCustomMetrics(config.GetCustomMetrics(), map[string][]string{
+		metrics.POD.LowerCase():  utils.ColumnNames(metrics.POD),
+		metrics.NODE.LowerCase(): utils.ColumnNames(metrics.NODE),
+	})
+}
+
+func columnsHelpStr(resource metrics.Resource) string {
+	return fmt.Sprintf("Comma separated list of extra columns to show. Available columns: %s.", strings.Join(utils.ColumnNames(resource), ", "))
+}
+
 func addKeyboardShortcutsToDescription(usage string) string {
 	return fmt.Sprintf("%s\n%s", usage, keyboardShortcuts)
 }
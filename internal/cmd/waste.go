@@ -0,0 +1,123 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/cmd/top"
+	"sigs.k8s.io/yaml"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
+)
+
+var (
+	wasteOpts     = &top.TopPodOptions{}
+	wasteSortBy   = "cpu"
+	wasteOutput   = "table"
+	wasteDuration = time.Minute
+	wasteCmd      = &cobra.Command{
+		Use:   "waste",
+		Short: "Report pods requesting more than they use",
+		Long: `Report pods requesting more than they use.
+
+The usage of the pods is watched for --duration and compared to their
+requests. Pods, workloads and namespaces are ranked by their unused cpu or
+memory, which is the request minus the peak usage that was seen so it can be
+taken away without going under anything that was observed. With
+--source=prometheus the history prometheus already has is used instead of
+waiting. Polls that fail are skipped and how many did is shown with the
+report.
+
+The same report is shown for the pods seen so far by pressing w in the pod
+view.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := validateCommonFlags(); err != nil {
+				return err
+			}
+			if err := validateSource(); err != nil {
+				return err
+			}
+			if err := metrics.ValidateWasteSort(wasteSortBy); err != nil {
+				return err
+			}
+			if wasteOutput != "table" && wasteOutput != "json" && wasteOutput != "yaml" {
+				return fmt.Errorf("invalid output provided: %s, must be one of: table, json, yaml", wasteOutput)
+			}
+			prometheus := config.GetPrometheus()
+			if settings.PrometheusURL != "" {
+				prometheus.URL = settings.PrometheusURL
+			}
+			client, err := metrics.New(flags, metrics.Options{
+				AllNamespaces: &wasteOpts.AllNamespaces,
+				ChunkSize:     settings.ChunkSize,
+				Source:        settings.Source,
+				Prometheus:    prometheus,
+			})
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			client.Watch(ctx, metrics.POD, wasteOpts.LabelSelector)
+			interval := time.Duration(settings.Interval) * time.Second
+			tracker, err := client.CollectWaste(ctx, wasteOpts, wasteDuration, interval, settings.Timeout)
+			if err != nil {
+				return err
+			}
+			report := tracker.Report(wasteSortBy)
+			switch wasteOutput {
+			case "json":
+				b, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(report)
+				if err != nil {
+					return err
+				}
+				fmt.Print(string(b))
+			default:
+				utils.WriteWasteReport(os.Stdout, report, 0)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	wasteCmd.Flags().StringVarP(&wasteOpts.LabelSelector, "selector", "l", wasteOpts.LabelSelector, selectorHelpStr)
+	wasteCmd.Flags().BoolVarP(&wasteOpts.AllNamespaces, "all-namespaces", "A", false, allNsHelpStr)
+	wasteCmd.Flags().StringVar(&wasteSortBy, "sort-by", wasteSortBy, "Rank by the unused 'cpu' or 'memory'.")
+	wasteCmd.Flags().StringVarP(&wasteOutput, "output", "o", wasteOutput, "Output format, one of: table, json, yaml.")
+	wasteCmd.Flags().DurationVar(&wasteDuration, "duration", wasteDuration, "How long to watch the usage of the pods for.")
+	addCommonFlags(wasteCmd)
+	addSourceFlags(wasteCmd)
+	// the usage is polled at a fixed interval rather than following the
+	// metrics-server cadence
+	wasteCmd.Flags().Lookup("interval").Usage = wasteIntervalHelpStr
+	rootCmd.AddCommand(wasteCmd)
+}
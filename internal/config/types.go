@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -12,14 +13,23 @@ const configPath = "~/.config/kubectl-topui/config.yml"
 
 var (
 	config          Config
+	configErr       error
 	once            sync.Once
 	defaultSelected = 13
 	defaultLimit    = 9
 	defaultUsage    = 10
+	defaultThrottle = 11
+	defaultAnomaly  = 14
+
+	defaultSensitivity = 3.0
 )
 
 type Config struct {
-	Theme Colors `json:"theme" yaml:"theme"`
+	Theme         Colors         `json:"theme" yaml:"theme"`
+	Prometheus    Prometheus     `json:"prometheus" yaml:"prometheus"`
+	CustomMetrics []CustomMetric `json:"customMetrics" yaml:"customMetrics"`
+	Pricing       Pricing        `json:"pricing" yaml:"pricing"`
+	Anomalies     Anomalies      `json:"anomalies" yaml:"anomalies"`
 }
 
 type Colors struct {
@@ -30,8 +40,78 @@ type Colors struct {
 	MemUsage int `json:"memUsage" yaml:"memUsage"`
 	Axis     int `json:"axis" yaml:"axis"`
 	Labels   int `json:"labels" yaml:"labels"`
+	// CPUThrottled is the throttling line drawn on the cpu graph
+	CPUThrottled int `json:"cpuThrottled" yaml:"cpuThrottled"`
+	// Anomaly marks the points of the cpu and memory graphs that were
+	// outside of their usual range
+	Anomaly int `json:"anomaly" yaml:"anomaly"`
+}
+
+// Anomalies configures how far a cpu or memory reading has to be from the
+// moving average of an object, in standard deviations, to be flagged. 0
+// turns it off.
+type Anomalies struct {
+	Sensitivity float64 `json:"sensitivity" yaml:"sensitivity"`
+}
+
+// Prometheus configures the prometheus metrics source. The queries are go
+// templates that get the namespace being watched (empty for all of them) and
+// the rate window. Pod queries need to return namespace and pod labels and
+// node queries a node label.
+type Prometheus struct {
+	URL        string `json:"url" yaml:"url"`
+	Window     string `json:"window" yaml:"window"`
+	PodCPU     string `json:"podCPU" yaml:"podCPU"`
+	PodMemory  string `json:"podMemory" yaml:"podMemory"`
+	NodeCPU    string `json:"nodeCPU" yaml:"nodeCPU"`
+	NodeMemory string `json:"nodeMemory" yaml:"nodeMemory"`
+}
+
+// CustomMetric is an extra column and graph fed from the custom or external
+// metrics api
+type CustomMetric struct {
+	// Name is the column header and graph title, it defaults to Metric
+	Name   string `json:"name" yaml:"name"`
+	Metric string `json:"metric" yaml:"metric"`
+	// Type is custom for custom.metrics.k8s.io or external for
+	// external.metrics.k8s.io
+	Type string `json:"type" yaml:"type"`
+	// Selector is the metric label selector
+	Selector string `json:"selector" yaml:"selector"`
+	// Resource is pods or nodes, external metrics only apply to pods and
+	// every pod in a namespace shows the namespace's value
+	Resource string `json:"resource" yaml:"resource"`
+}
+
+// Pricing is used to estimate what pods cost. Pods on nodes matching one of
+// the Nodes selectors use its prices instead of the defaults.
+type Pricing struct {
+	CPUCoreHour   float64       `json:"cpuCoreHour" yaml:"cpuCoreHour"`
+	MemoryGiBHour float64       `json:"memoryGiBHour" yaml:"memoryGiBHour"`
+	Nodes         []NodePricing `json:"nodes" yaml:"nodes"`
 }
 
+// Enabled returns whether any prices were configured
+func (p Pricing) Enabled() bool {
+	return p.CPUCoreHour > 0 || p.MemoryGiBHour > 0 || len(p.Nodes) > 0
+}
+
+// NodePricing are the prices of the nodes matching a label selector, ie
+// node.kubernetes.io/instance-type=m5.large
+type NodePricing struct {
+	Selector      string  `json:"selector" yaml:"selector"`
+	CPUCoreHour   float64 `json:"cpuCoreHour" yaml:"cpuCoreHour"`
+	MemoryGiBHour float64 `json:"memoryGiBHour" yaml:"memoryGiBHour"`
+}
+
+const (
+	defaultWindow     = "1m"
+	defaultPodCPU     = `sum by (namespace, pod) (rate(container_cpu_usage_seconds_total{container!="", container!="POD"{{if .Namespace}}, namespace="{{.Namespace}}"{{end}}}[{{.Window}}]))`
+	defaultPodMemory  = `sum by (namespace, pod) (container_memory_working_set_bytes{container!="", container!="POD"{{if .Namespace}}, namespace="{{.Namespace}}"{{end}}})`
+	defaultNodeCPU    = `sum by (node) (rate(container_cpu_usage_seconds_total{id="/"}[{{.Window}}]))`
+	defaultNodeMemory = `sum by (node) (container_memory_working_set_bytes{id="/"})`
+)
+
 func initConfig() {
 	once.Do(func() {
 		defaultColor := 231
@@ -44,35 +124,78 @@ func initConfig() {
 		viper.SetDefault("theme.selected", defaultSelected)
 		viper.SetDefault("theme.cpuLimit", defaultLimit)
 		viper.SetDefault("theme.cpuUsage", defaultUsage)
+		viper.SetDefault("theme.cpuThrottled", defaultThrottle)
+		viper.SetDefault("theme.anomaly", defaultAnomaly)
 		viper.SetDefault("theme.memLimit", defaultLimit)
 		viper.SetDefault("theme.memUsage", defaultUsage)
 		viper.SetDefault("theme.axis", defaultColor)
 		viper.SetDefault("theme.labels", defaultColor)
+		viper.SetDefault("anomalies.sensitivity", defaultSensitivity)
+		viper.SetDefault("prometheus.window", defaultWindow)
+		viper.SetDefault("prometheus.podCPU", defaultPodCPU)
+		viper.SetDefault("prometheus.podMemory", defaultPodMemory)
+		viper.SetDefault("prometheus.nodeCPU", defaultNodeCPU)
+		viper.SetDefault("prometheus.nodeMemory", defaultNodeMemory)
+		// a missing config file just means the defaults are used
 		if err := viper.ReadInConfig(); err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				// Config file not found use default
-				config = Config{Theme: Colors{
-					Selected: defaultSelected,
-					CPULimit: defaultLimit,
-					CPUUsage: defaultUsage,
-					MemLimit: defaultLimit,
-					MemUsage: defaultUsage,
-					Axis:     defaultColor,
-					Labels:   defaultColor,
-				}}
+			var notFound viper.ConfigFileNotFoundError
+			if !errors.As(err, &notFound) {
+				configErr = fmt.Errorf("reading %s: %w", configPath, err)
 				return
 			}
 		}
 		var c Config
 		if err := viper.Unmarshal(&c); err != nil {
-			fmt.Println("unmarshal error", err)
+			configErr = fmt.Errorf("reading %s: %w", configPath, err)
 			return
 		}
 		config = c
 	})
 }
 
+// Load reads the config file and returns why it couldn't be used, a missing
+// file isn't an error
+func Load() error {
+	initConfig()
+	return configErr
+}
+
 func GetTheme() Colors {
 	initConfig()
 	return config.Theme
 }
+
+func GetPrometheus() Prometheus {
+	initConfig()
+	return config.Prometheus
+}
+
+func GetAnomalies() Anomalies {
+	initConfig()
+	return config.Anomalies
+}
+
+func GetPricing() Pricing {
+	initConfig()
+	return config.Pricing
+}
+
+// GetCustomMetrics returns the configured custom and external metrics with
+// the defaults filled in
+func GetCustomMetrics() []CustomMetric {
+	initConfig()
+	metrics := []CustomMetric{}
+	for _, m := range config.CustomMetrics {
+		if m.Name == "" {
+			m.Name = m.Metric
+		}
+		if m.Type == "" {
+			m.Type = "custom"
+		}
+		if m.Resource == "" {
+			m.Resource = "pods"
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
@@ -0,0 +1,228 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	autoscalinglisters "k8s.io/client-go/listers/autoscaling/v2"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/metadata/metadatainformer"
+	toolscache "k8s.io/client-go/tools/cache"
+	metricsapi "k8s.io/metrics/pkg/apis/metrics"
+)
+
+// cache holds the watch based pod and node metadata that gets joined onto
+// the metrics along with the most recent metrics so the values can be
+// rebuilt when the metadata changes between calls to the metrics api
+type cache struct {
+	mu      sync.Mutex
+	synced  []toolscache.InformerSynced
+	pods    corelisters.PodLister
+	nodes   corelisters.NodeLister
+	pvcs    corelisters.PersistentVolumeClaimLister
+	hpas    autoscalinglisters.HorizontalPodAutoscalerLister
+	changes chan struct{}
+
+	// allPods is set when the pod informer has every pod of the namespace
+	// rather than only the ones matching a selector
+	allPods bool
+
+	// nodeMeta has only the metadata of the nodes, their labels pick the
+	// prices of the pods
+	nodeMeta toolscache.GenericLister
+
+	podMetrics  []metricsapi.PodMetrics
+	nodeMetrics []metricsapi.NodeMetrics
+	volumes     map[string]volumeStats
+}
+
+func newCache() *cache {
+	return &cache{changes: make(chan struct{}, 1)}
+}
+
+// Watch starts the informers for the given resource. They run until the
+// context is cancelled.
+func (m MetricsClient) Watch(ctx context.Context, resource Resource, selector string) {
+	tweak := informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+		o.LabelSelector = selector
+	})
+	handler := toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { m.cache.notify() },
+		UpdateFunc: func(interface{}, interface{}) { m.cache.notify() },
+		DeleteFunc: func(interface{}) { m.cache.notify() },
+	}
+
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	switch resource {
+	case POD:
+		factory := informers.NewSharedInformerFactoryWithOptions(m.k, 0, informers.WithNamespace(m.ns), tweak)
+		informer := factory.Core().V1().Pods()
+		informer.Informer().AddEventHandler(handler)
+		_ = informer.Informer().SetTransform(stripManagedFields)
+		m.cache.pods = informer.Lister()
+		m.cache.allPods = selector == ""
+		m.cache.synced = append(m.cache.synced, informer.Informer().HasSynced)
+		factory.Start(ctx.Done())
+		if m.pricer != nil && len(m.pricer.nodes) > 0 && m.meta != nil {
+			// the node labels pick the prices, they aren't waited on since
+			// the default prices are used until they show up
+			nodes := metadatainformer.NewSharedInformerFactory(m.meta, 0)
+			informer := nodes.ForResource(v1.SchemeGroupVersion.WithResource("nodes"))
+			_ = informer.Informer().SetTransform(stripManagedFields)
+			m.cache.nodeMeta = informer.Lister()
+			nodes.Start(ctx.Done())
+		}
+	case PVC:
+		// every pod in the namespace is needed to find the ones mounting
+		// the claims, the selector only applies to the claims
+		pods := informers.NewSharedInformerFactoryWithOptions(m.k, 0, informers.WithNamespace(m.ns))
+		podInformer := pods.Core().V1().Pods()
+		podInformer.Informer().AddEventHandler(handler)
+		_ = podInformer.Informer().SetTransform(stripManagedFields)
+		m.cache.pods = podInformer.Lister()
+		m.cache.allPods = true
+		m.cache.synced = append(m.cache.synced, podInformer.Informer().HasSynced)
+		pods.Start(ctx.Done())
+
+		factory := informers.NewSharedInformerFactoryWithOptions(m.k, 0, informers.WithNamespace(m.ns), tweak)
+		informer := factory.Core().V1().PersistentVolumeClaims()
+		informer.Informer().AddEventHandler(handler)
+		_ = informer.Informer().SetTransform(stripManagedFields)
+		m.cache.pvcs = informer.Lister()
+		m.cache.synced = append(m.cache.synced, informer.Informer().HasSynced)
+		factory.Start(ctx.Done())
+	case HPA:
+		factory := informers.NewSharedInformerFactoryWithOptions(m.k, 0, informers.WithNamespace(m.ns), tweak)
+		informer := factory.Autoscaling().V2().HorizontalPodAutoscalers()
+		informer.Informer().AddEventHandler(handler)
+		_ = informer.Informer().SetTransform(stripManagedFields)
+		m.cache.hpas = informer.Lister()
+		m.cache.synced = append(m.cache.synced, informer.Informer().HasSynced)
+		factory.Start(ctx.Done())
+	default:
+		factory := informers.NewSharedInformerFactoryWithOptions(m.k, 0, tweak)
+		informer := factory.Core().V1().Nodes()
+		informer.Informer().AddEventHandler(handler)
+		_ = informer.Informer().SetTransform(stripManagedFields)
+		m.cache.nodes = informer.Lister()
+		m.cache.synced = append(m.cache.synced, informer.Informer().HasSynced)
+		factory.Start(ctx.Done())
+	}
+}
+
+// stripManagedFields drops the managed fields before the objects are stored,
+// they are never read from the cache and are often most of the object
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// WaitForSync blocks until the informers started by Watch have done their
+// initial list. It should be called without the per call timeout since the
+// first list of a big cluster can take longer than a single request.
+func (m MetricsClient) WaitForSync(ctx context.Context) error {
+	return m.cache.waitForSync(ctx)
+}
+
+// Changes receives whenever the watched objects change. Multiple
+// changes are coalesced into one until it is read.
+func (m MetricsClient) Changes() <-chan struct{} {
+	return m.cache.changes
+}
+
+func (c *cache) notify() {
+	select {
+	case c.changes <- struct{}{}:
+	default:
+	}
+}
+
+// waitForSync blocks until the informers have done their initial list
+func (c *cache) waitForSync(ctx context.Context) error {
+	c.mu.Lock()
+	synced := c.synced
+	c.mu.Unlock()
+	if len(synced) == 0 {
+		return errors.New("informers were not started")
+	}
+	if !toolscache.WaitForCacheSync(ctx.Done(), synced...) {
+		return errors.New("timed out waiting for caches to sync")
+	}
+	return nil
+}
+
+// namespacePods returns every pod of the namespace from the informer, it
+// returns false when the informer isn't running or doesn't have all of them
+func (m MetricsClient) namespacePods(ctx context.Context, ns string) ([]*v1.Pod, bool, error) {
+	m.cache.mu.Lock()
+	pods, all := m.cache.pods, m.cache.allPods
+	m.cache.mu.Unlock()
+	if pods == nil || !all || (m.ns != metav1.NamespaceAll && m.ns != ns) {
+		return nil, false, nil
+	}
+	if err := m.cache.waitForSync(ctx); err != nil {
+		return nil, true, err
+	}
+	list, err := pods.Pods(ns).List(labels.Everything())
+	return list, true, err
+}
+
+func (c *cache) setPodMetrics(items []metricsapi.PodMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.podMetrics = items
+}
+
+func (c *cache) getPodMetrics() []metricsapi.PodMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.podMetrics
+}
+
+func (c *cache) setNodeMetrics(items []metricsapi.NodeMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nodeMetrics = items
+}
+
+func (c *cache) getNodeMetrics() []metricsapi.NodeMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodeMetrics
+}
+
+func (c *cache) setVolumes(volumes map[string]volumeStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.volumes = volumes
+}
+
+func (c *cache) getVolumes() map[string]volumeStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.volumes
+}
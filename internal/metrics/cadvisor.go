@@ -0,0 +1,214 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Throttling is how much of the time a pod wanted to run it was held back
+// by its cpu limit, from the cfs counters cadvisor reports
+type Throttling struct {
+	// Periods and ThrottledPeriods are the cfs periods since the previous
+	// reading, summed over the containers of the pod
+	Periods          int64
+	ThrottledPeriods int64
+}
+
+// Percent returns the share of the periods that were throttled
+func (t Throttling) Percent() float64 {
+	if t.Periods == 0 {
+		return 0
+	}
+	return float64(t.ThrottledPeriods) / float64(t.Periods) * 100
+}
+
+// cfsCounter is the last reading of the cumulative cfs counters of a pod
+type cfsCounter struct {
+	periods   float64
+	throttled float64
+	at        time.Time
+}
+
+// nodeThrottling is the latest throttling of the pods on a node by their key
+type nodeThrottling struct {
+	counters   map[string]cfsCounter
+	throttling map[string]Throttling
+}
+
+// throttleCollector reads the cadvisor metrics of the focused node and keeps
+// the throttling of every pod on it. The counters are turned into the share
+// of throttled periods using the previous reading. The full cadvisor output
+// of a node is big so only the node of the selected pod is read.
+type throttleCollector struct {
+	k *kubernetes.Clientset
+
+	mu    sync.Mutex
+	nodes map[string]*nodeThrottling
+	focus string
+	// err is why the focused node couldn't be read in the last collect
+	err error
+}
+
+func newThrottleCollector(k *kubernetes.Clientset) *throttleCollector {
+	return &throttleCollector{k: k, nodes: map[string]*nodeThrottling{}}
+}
+
+// setFocus changes the node that is read, the throttling of the pods on the
+// previous one is dropped so it doesn't go stale
+func (c *throttleCollector) setFocus(node string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if node == c.focus {
+		return
+	}
+	c.focus = node
+	c.err = nil
+	for name := range c.nodes {
+		if name != node {
+			delete(c.nodes, name)
+		}
+	}
+}
+
+// collect reads cadvisor on the focused node when any of the pods are on it
+// and sets their throttling. Like the summary stats this is extra, a node
+// that can't be read just leaves its pods without it and the error is kept.
+func (c *throttleCollector) collect(ctx context.Context, values []MetricValue) {
+	c.mu.Lock()
+	focus := c.focus
+	c.mu.Unlock()
+	nodes := map[string]bool{}
+	for _, v := range values {
+		if v.Node != "" && v.Node == focus {
+			nodes[v.Node] = true
+			break
+		}
+	}
+	// only the focused node is read so it either worked or failed
+	_, err := eachNode(ctx, nodes, func(ctx context.Context, name string) error {
+		data, err := c.k.CoreV1().RESTClient().Get().
+			AbsPath("/api/v1/nodes", name, "proxy", "metrics", "cadvisor").
+			Do(ctx).Raw()
+		if err != nil {
+			return err
+		}
+		samples, err := parsePromText(data)
+		if err != nil {
+			return err
+		}
+		c.update(name, samples)
+		return nil
+	})
+	c.mu.Lock()
+	if focus == c.focus {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.apply(values)
+}
+
+// lastErr returns why the focused node couldn't be read
+func (c *throttleCollector) lastErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// apply sets the latest throttling on the values without reading the nodes
+func (c *throttleCollector) apply(values []MetricValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range values {
+		n, ok := c.nodes[values[i].Node]
+		if !ok {
+			continue
+		}
+		if t, ok := n.throttling[values[i].Key()]; ok {
+			values[i].Throttling = &t
+		}
+	}
+}
+
+func (c *throttleCollector) update(name string, samples []promSample) {
+	// the containers of a pod are summed, the pod cgroup itself and the
+	// pause container are left out
+	readings := map[string]*cfsCounter{}
+	for _, s := range samples {
+		if s.name != "container_cpu_cfs_periods_total" && s.name != "container_cpu_cfs_throttled_periods_total" {
+			continue
+		}
+		if container := s.labels["container"]; container == "" || container == "POD" {
+			continue
+		}
+		key := s.labels["namespace"] + "/" + s.labels["pod"]
+		r, ok := readings[key]
+		if !ok {
+			r = &cfsCounter{}
+			readings[key] = r
+		}
+		if s.name == "container_cpu_cfs_periods_total" {
+			r.periods += s.value
+		} else {
+			r.throttled += s.value
+		}
+		at := s.timestamp
+		if at.IsZero() {
+			at = time.Now()
+		}
+		if at.After(r.at) {
+			r.at = at
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if name != c.focus {
+		// the selection moved on while it was being read
+		return
+	}
+	// pods that are gone are dropped along with the old counters
+	prev, ok := c.nodes[name]
+	if !ok {
+		prev = &nodeThrottling{}
+	}
+	cur := &nodeThrottling{counters: map[string]cfsCounter{}, throttling: map[string]Throttling{}}
+	c.nodes[name] = cur
+	for key, r := range readings {
+		last, ok := prev.counters[key]
+		if ok && !r.at.After(last.at) {
+			// nothing new, keep the last throttling
+			cur.counters[key] = last
+			if t, ok := prev.throttling[key]; ok {
+				cur.throttling[key] = t
+			}
+			continue
+		}
+		cur.counters[key] = *r
+		if !ok || r.periods < last.periods || r.throttled < last.throttled {
+			// the first reading or a container restarted
+			continue
+		}
+		cur.throttling[key] = Throttling{
+			Periods:          int64(r.periods - last.periods),
+			ThrottledPeriods: int64(r.throttled - last.throttled),
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// HoursPerMonth is what the monthly estimates are based on
+const HoursPerMonth = 730
+
+const gib = 1024 * 1024 * 1024
+
+// Cost is the estimated hourly cost of a pod by what it requested and by
+// what it actually used
+type Cost struct {
+	Requested float64
+	Used      float64
+}
+
+// Add returns the sum of both costs
+func (c Cost) Add(o Cost) Cost {
+	return Cost{Requested: c.Requested + o.Requested, Used: c.Used + o.Used}
+}
+
+// Monthly returns the cost over a month
+func (c Cost) Monthly() Cost {
+	return Cost{Requested: c.Requested * HoursPerMonth, Used: c.Used * HoursPerMonth}
+}
+
+// ValidatePricing checks the pricing from the config file
+func ValidatePricing(p config.Pricing) error {
+	_, err := newPricer(p)
+	return err
+}
+
+// pricer has the prices per core hour and GiB hour
+type pricer struct {
+	cpu   float64
+	mem   float64
+	nodes []nodePrice
+}
+
+type nodePrice struct {
+	selector labels.Selector
+	cpu      float64
+	mem      float64
+}
+
+func newPricer(p config.Pricing) (*pricer, error) {
+	pr := &pricer{cpu: p.CPUCoreHour, mem: p.MemoryGiBHour}
+	for _, n := range p.Nodes {
+		selector, err := labels.Parse(n.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("node pricing has invalid selector %q: %w", n.Selector, err)
+		}
+		if selector.Empty() {
+			return nil, fmt.Errorf("node pricing needs a selector")
+		}
+		pr.nodes = append(pr.nodes, nodePrice{selector: selector, cpu: n.CPUCoreHour, mem: n.MemoryGiBHour})
+	}
+	return pr, nil
+}
+
+// rates returns the prices of the node, the first matching selector wins
+func (p *pricer) rates(node labels.Set) (float64, float64) {
+	for _, n := range p.nodes {
+		if node != nil && n.selector.Matches(node) {
+			return n.cpu, n.mem
+		}
+	}
+	return p.cpu, p.mem
+}
+
+func (p *pricer) cost(node labels.Set, cpuRequest, memRequest, cpuUsage, memUsage resource.Quantity) *Cost {
+	cpu, mem := p.rates(node)
+	price := func(cores, bytes resource.Quantity) float64 {
+		return float64(cores.MilliValue())/1000*cpu + float64(bytes.Value())/gib*mem
+	}
+	return &Cost{
+		Requested: price(cpuRequest, memRequest),
+		Used:      price(cpuUsage, memUsage),
+	}
+}
+
+// nodeLabels returns the labels of the node when the nodes are being
+// watched for the prices
+func (c *cache) nodeLabels(name string) labels.Set {
+	if c.nodeMeta == nil || name == "" {
+		return nil
+	}
+	obj, err := c.nodeMeta.Get(name)
+	if err != nil {
+		return nil
+	}
+	node, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	return node.GetLabels()
+}
+
+// workload returns the kind and name of what created the pod, the pods of
+// a deployment's replica sets are put under the deployment
+func workload(pod v1.Pod) string {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return "Pod/" + pod.Name
+	}
+	if owner.Kind == "ReplicaSet" {
+		hash := pod.Labels["pod-template-hash"]
+		if hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return "Deployment/" + strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+	return owner.Kind + "/" + owner.Name
+}
@@ -0,0 +1,296 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	custommetrics "k8s.io/metrics/pkg/client/custom_metrics"
+	externalmetrics "k8s.io/metrics/pkg/client/external_metrics"
+)
+
+const (
+	customMetricType   = "custom"
+	externalMetricType = "external"
+
+	// how many requests to the metrics apis run at the same time
+	customConcurrency = 8
+)
+
+// ValidateCustomMetrics checks the custom metrics from the config file. The
+// columns are the names of the built in columns by resource, a metric can't
+// be named like one of them.
+func ValidateCustomMetrics(metrics []config.CustomMetric, columns map[string][]string) error {
+	names := map[string]bool{}
+	for _, column := range columns[POD.LowerCase()] {
+		names[POD.LowerCase()+"/"+column] = true
+	}
+	for _, column := range columns[NODE.LowerCase()] {
+		names[NODE.LowerCase()+"/"+column] = true
+	}
+	configured := map[string]bool{}
+	for _, m := range metrics {
+		if m.Metric == "" {
+			return fmt.Errorf("custom metric %q has no metric name", m.Name)
+		}
+		if m.Type != customMetricType && m.Type != externalMetricType {
+			return fmt.Errorf("custom metric %q has invalid type %q, must be custom or external", m.Name, m.Type)
+		}
+		if m.Resource != POD.LowerCase() && m.Resource != NODE.LowerCase() {
+			return fmt.Errorf("custom metric %q has invalid resource %q, must be pods or nodes", m.Name, m.Resource)
+		}
+		if m.Type == externalMetricType && m.Resource != POD.LowerCase() {
+			return fmt.Errorf("external metric %q can only be shown for pods", m.Name)
+		}
+		if _, err := labels.Parse(m.Selector); err != nil {
+			return fmt.Errorf("custom metric %q has invalid selector: %w", m.Name, err)
+		}
+		if names[m.Resource+"/"+m.Name] {
+			return fmt.Errorf("custom metric %q has the same name as a built in column", m.Name)
+		}
+		if configured[m.Resource+"/"+m.Name] {
+			return fmt.Errorf("custom metric %q is configured more than once", m.Name)
+		}
+		configured[m.Resource+"/"+m.Name] = true
+	}
+	return nil
+}
+
+// CustomMetrics returns the configured metrics shown for the resource
+func CustomMetrics(resource Resource) []config.CustomMetric {
+	metrics := []config.CustomMetric{}
+	for _, m := range config.GetCustomMetrics() {
+		if m.Resource == resource.LowerCase() {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// customCollector reads the configured metrics from the custom and external
+// metrics apis and keeps the last values of every object by their key
+type customCollector struct {
+	metrics  []config.CustomMetric
+	custom   custommetrics.CustomMetricsClient
+	external externalmetrics.ExternalMetricsClient
+
+	mu     sync.Mutex
+	values map[string]map[string]float64
+	// errs are the first error of each metric by name in the last collect
+	errs map[string]error
+}
+
+// newCustomCollector creates the clients for the metrics apis. They don't
+// take a context so every request is bounded by the timeout as well.
+func newCustomCollector(f cmdutil.Factory, metrics []config.CustomMetric, timeout time.Duration) (*customCollector, error) {
+	restConfig, err := f.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Timeout = timeout
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+	discovery, err := f.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	external, err := externalmetrics.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &customCollector{
+		metrics:  metrics,
+		custom:   custommetrics.NewForConfig(restConfig, mapper, custommetrics.NewAvailableAPIsGetter(discovery)),
+		external: external,
+		values:   map[string]map[string]float64{},
+		errs:     map[string]error{},
+	}, nil
+}
+
+// customRequest is a single request to the metrics apis, read returns the
+// values it got by the key of the object
+type customRequest struct {
+	metric string
+	read   func() (map[string]float64, error)
+}
+
+// collect reads every configured metric for the values and sets them. A
+// metric that can't be read leaves its column empty instead of failing the
+// refresh, the adapters often only serve some of the objects. The first
+// error of each metric is kept.
+func (c *customCollector) collect(ctx context.Context, values []MetricValue) {
+	namespaces := map[string]bool{}
+	for _, v := range values {
+		if v.Namespace != "" {
+			namespaces[v.Namespace] = true
+		}
+	}
+	requests := []customRequest{}
+	for _, m := range c.metrics {
+		m := m
+		selector, _ := labels.Parse(m.Selector)
+		switch {
+		case m.Type == externalMetricType:
+			// external metrics aren't tied to an object, every pod in the
+			// namespace gets the sum of the matching series
+			for ns := range namespaces {
+				ns := ns
+				requests = append(requests, customRequest{metric: m.Name, read: func() (map[string]float64, error) {
+					list, err := c.external.NamespacedMetrics(ns).List(m.Metric, selector)
+					if err != nil {
+						return nil, err
+					}
+					var sum float64
+					for _, item := range list.Items {
+						sum += item.Value.AsApproximateFloat64()
+					}
+					read := map[string]float64{}
+					for _, v := range values {
+						if v.Namespace == ns {
+							read[v.Key()] = sum
+						}
+					}
+					return read, nil
+				}})
+			}
+		case m.Resource == NODE.LowerCase():
+			requests = append(requests, customRequest{metric: m.Name, read: func() (map[string]float64, error) {
+				list, err := c.custom.RootScopedMetrics().GetForObjects(schema.GroupKind{Kind: "Node"}, labels.Everything(), m.Metric, selector)
+				if err != nil {
+					return nil, err
+				}
+				read := map[string]float64{}
+				for _, item := range list.Items {
+					read[item.DescribedObject.Name] = item.Value.AsApproximateFloat64()
+				}
+				return read, nil
+			}})
+		default:
+			// the custom metrics api can't list pods across namespaces
+			for ns := range namespaces {
+				ns := ns
+				requests = append(requests, customRequest{metric: m.Name, read: func() (map[string]float64, error) {
+					list, err := c.custom.NamespacedMetrics(ns).GetForObjects(schema.GroupKind{Kind: "Pod"}, labels.Everything(), m.Metric, selector)
+					if err != nil {
+						return nil, err
+					}
+					read := map[string]float64{}
+					for _, item := range list.Items {
+						read[ns+"/"+item.DescribedObject.Name] = item.Value.AsApproximateFloat64()
+					}
+					return read, nil
+				}})
+			}
+		}
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	current := map[string]map[string]float64{}
+	errs := map[string]error{}
+	sem := make(chan struct{}, customConcurrency)
+	for _, r := range requests {
+		wg.Add(1)
+		go func(r customRequest) {
+			defer wg.Done()
+			var read map[string]float64
+			var err error
+			select {
+			case sem <- struct{}{}:
+				read, err = withContext(ctx, r.read)
+				<-sem
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if errs[r.metric] == nil {
+					errs[r.metric] = err
+				}
+				return
+			}
+			for key, value := range read {
+				if current[key] == nil {
+					current[key] = map[string]float64{}
+				}
+				current[key][r.metric] = value
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	c.values = current
+	c.errs = errs
+	c.mu.Unlock()
+	c.apply(values)
+}
+
+// withContext stops waiting on read once the context is done. The clients
+// of the metrics apis don't take a context, the request itself is left to
+// finish within the client timeout.
+func withContext(ctx context.Context, read func() (map[string]float64, error)) (map[string]float64, error) {
+	type result struct {
+		read map[string]float64
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, err := read()
+		done <- result{r, err}
+	}()
+	select {
+	case r := <-done:
+		return r.read, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lastErrs returns the first error of every configured metric in the last
+// collect, the ones that could be read are nil
+func (c *customCollector) lastErrs() map[string]error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	errs := map[string]error{}
+	for _, m := range c.metrics {
+		errs[m.Name] = c.errs[m.Name]
+	}
+	return errs
+}
+
+// apply sets the last values on the objects without reading the apis
+func (c *customCollector) apply(values []MetricValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range values {
+		if custom, ok := c.values[values[i].Key()]; ok {
+			values[i].Custom = custom
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics/v1beta1"
+	externalmetrics "k8s.io/metrics/pkg/client/external_metrics"
+)
+
+// fakeExternal answers the external metrics of every namespace with its
+// value after the delay, the namespaces in fail get an error instead
+type fakeExternal struct {
+	delay time.Duration
+	value int64
+	fail  map[string]bool
+
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (f *fakeExternal) NamespacedMetrics(ns string) externalmetrics.MetricsInterface {
+	return fakeExternalNamespace{f, ns}
+}
+
+type fakeExternalNamespace struct {
+	f  *fakeExternal
+	ns string
+}
+
+func (n fakeExternalNamespace) List(string, labels.Selector) (*v1beta1.ExternalMetricValueList, error) {
+	n.f.mu.Lock()
+	n.f.running++
+	if n.f.running > n.f.peak {
+		n.f.peak = n.f.running
+	}
+	n.f.mu.Unlock()
+	defer func() {
+		n.f.mu.Lock()
+		n.f.running--
+		n.f.mu.Unlock()
+	}()
+	time.Sleep(n.f.delay)
+	if n.f.fail[n.ns] {
+		return nil, fmt.Errorf("no metrics in %s", n.ns)
+	}
+	return &v1beta1.ExternalMetricValueList{Items: []v1beta1.ExternalMetricValue{
+		{Value: *resource.NewQuantity(n.f.value, resource.DecimalSI)},
+	}}, nil
+}
+
+func externalCollector(f *fakeExternal) *customCollector {
+	return &customCollector{
+		metrics:  []config.CustomMetric{{Name: "queue", Type: externalMetricType, Resource: "pods", Metric: "queue_depth"}},
+		external: f,
+		values:   map[string]map[string]float64{},
+		errs:     map[string]error{},
+	}
+}
+
+func podsIn(namespaces int) []MetricValue {
+	values := []MetricValue{}
+	for i := 0; i < namespaces; i++ {
+		values = append(values, MetricValue{Name: "web", Namespace: fmt.Sprintf("ns-%d", i)})
+	}
+	return values
+}
+
+func TestCustomCollect(t *testing.T) {
+	f := &fakeExternal{delay: 10 * time.Millisecond, value: 7, fail: map[string]bool{"ns-3": true}}
+	c := externalCollector(f)
+	values := podsIn(3 * customConcurrency)
+	c.collect(context.Background(), values)
+
+	for _, v := range values {
+		got, ok := v.Custom["queue"]
+		if v.Namespace == "ns-3" {
+			if ok {
+				t.Errorf("%s: expected no value, got %v", v.Key(), got)
+			}
+			continue
+		}
+		if got != 7 {
+			t.Errorf("%s: expected 7, got %v", v.Key(), got)
+		}
+	}
+	if err := c.lastErrs()["queue"]; err == nil {
+		t.Error("expected the error of ns-3 to be kept")
+	}
+	if f.peak > customConcurrency {
+		t.Errorf("expected at most %d requests at once, got %d", customConcurrency, f.peak)
+	}
+}
+
+func TestCustomCollectCancel(t *testing.T) {
+	f := &fakeExternal{delay: time.Second, value: 7}
+	c := externalCollector(f)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	c.collect(ctx, podsIn(4*customConcurrency))
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected collect to stop with its context, took %s", elapsed)
+	}
+	if err := c.lastErrs()["queue"]; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
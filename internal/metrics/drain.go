@@ -0,0 +1,297 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// DrainReport is the result of simulating a drain of a node. The pods that
+// would be evicted are bin-packed into the free allocatable of the other
+// schedulable and ready nodes using their effective requests.
+type DrainReport struct {
+	Node          string           `json:"node"`
+	Evicted       int              `json:"evictedPods"`
+	Skipped       []string         `json:"skippedPods,omitempty"`
+	Fits          bool             `json:"fits"`
+	Unschedulable []DrainPlacement `json:"unschedulable,omitempty"`
+	Placements    []DrainPlacement `json:"placements,omitempty"`
+	Remaining     []NodeCapacity   `json:"remainingCapacity,omitempty"`
+}
+
+// DrainPlacement is where a single pod would land after the drain
+type DrainPlacement struct {
+	Pod        string `json:"pod"`
+	CPURequest string `json:"cpuRequest"`
+	MemRequest string `json:"memRequest"`
+	Node       string `json:"node,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// NodeCapacity is the free allocatable left on a node after the drain
+type NodeCapacity struct {
+	Node string `json:"node"`
+	CPU  string `json:"cpu"`
+	Mem  string `json:"memory"`
+	Pods int64  `json:"pods"`
+}
+
+type drainNode struct {
+	node *v1.Node
+	cpu  resource.Quantity
+	mem  resource.Quantity
+	pods int64
+}
+
+// GetNodeDrain simulates draining the named node and returns the report as yaml
+func (m MetricsClient) GetNodeDrain(ctx context.Context, name string) (string, error) {
+	nodes := []v1.Node{}
+	listNodes := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return m.k.CoreV1().Nodes().List(ctx, opts)
+	}
+	err := m.eachListItem(ctx, metav1.ListOptions{}, listNodes, func(obj runtime.Object) error {
+		nodes = append(nodes, *obj.(*v1.Node))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	pods := []v1.Pod{}
+	listPods := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return m.k.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+	}
+	err = m.eachListItem(ctx, metav1.ListOptions{}, listPods, func(obj runtime.Object) error {
+		pods = append(pods, *obj.(*v1.Pod))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	report := simulateDrain(name, nodes, pods)
+	s, err := yaml.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+func simulateDrain(name string, nodes []v1.Node, pods []v1.Pod) DrainReport {
+	report := DrainReport{Node: name, Fits: true}
+
+	// start every other schedulable and ready node with its allocatable and
+	// subtract the requests of the pods that are already running there
+	candidates := map[string]*drainNode{}
+	for i := range nodes {
+		n := &nodes[i]
+		if n.Name == name || n.Spec.Unschedulable || !nodeReady(n) {
+			continue
+		}
+		candidates[n.Name] = &drainNode{
+			node: n,
+			cpu:  n.Status.Allocatable.Cpu().DeepCopy(),
+			mem:  n.Status.Allocatable.Memory().DeepCopy(),
+			pods: n.Status.Allocatable.Pods().Value(),
+		}
+	}
+	var evicted []v1.Pod
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if pod.Spec.NodeName == name {
+			if isDaemonSetPod(pod) {
+				continue
+			}
+			if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+				report.Skipped = append(report.Skipped, podKey(pod))
+				continue
+			}
+			evicted = append(evicted, pod)
+			continue
+		}
+		if c, ok := candidates[pod.Spec.NodeName]; ok {
+			req := effectiveRequests(pod)
+			c.cpu.Sub(req.cpu)
+			c.mem.Sub(req.mem)
+			c.pods--
+		}
+	}
+	report.Evicted = len(evicted)
+
+	// first fit decreasing, largest cpu request first onto the nodes with
+	// the most free cpu so the result roughly matches the default spreading
+	sort.SliceStable(evicted, func(i, j int) bool {
+		a, b := effectiveRequests(evicted[i]), effectiveRequests(evicted[j])
+		if c := a.cpu.Cmp(b.cpu); c != 0 {
+			return c > 0
+		}
+		return a.mem.Cmp(b.mem) > 0
+	})
+	for _, pod := range evicted {
+		req := effectiveRequests(pod)
+		placement := DrainPlacement{
+			Pod:        podKey(pod),
+			CPURequest: req.cpu.String(),
+			MemRequest: req.mem.String(),
+		}
+		ordered := sortedCandidates(candidates)
+		reason := "no schedulable nodes"
+		for _, c := range ordered {
+			if r := fits(pod, req, c); r != "" {
+				reason = r
+				continue
+			}
+			c.cpu.Sub(req.cpu)
+			c.mem.Sub(req.mem)
+			c.pods--
+			placement.Node = c.node.Name
+			reason = ""
+			break
+		}
+		if placement.Node == "" {
+			placement.Reason = reason
+			report.Fits = false
+			report.Unschedulable = append(report.Unschedulable, placement)
+		} else {
+			report.Placements = append(report.Placements, placement)
+		}
+	}
+
+	for _, c := range sortedCandidates(candidates) {
+		report.Remaining = append(report.Remaining, NodeCapacity{
+			Node: c.node.Name,
+			CPU:  fmt.Sprintf("%dm", c.cpu.MilliValue()),
+			Mem:  fmt.Sprintf("%dMi", c.mem.Value()/DIVISOR),
+			Pods: c.pods,
+		})
+	}
+	return report
+}
+
+// fits returns the reason the pod can't be placed on the node or an empty
+// string if it can. Only node selectors and taints are considered, node
+// affinity and topology spread constraints are not evaluated.
+func fits(pod v1.Pod, req podRequests, c *drainNode) string {
+	if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(c.node.Labels)) {
+		return "node selector does not match"
+	}
+	for i := range c.node.Spec.Taints {
+		taint := &c.node.Spec.Taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(pod.Spec.Tolerations, taint) {
+			return fmt.Sprintf("untolerated taint %s", taint.ToString())
+		}
+	}
+	if c.pods < 1 {
+		return "insufficient pods"
+	}
+	if c.cpu.Cmp(req.cpu) < 0 {
+		return "insufficient cpu"
+	}
+	if c.mem.Cmp(req.mem) < 0 {
+		return "insufficient memory"
+	}
+	return ""
+}
+
+type podRequests struct {
+	cpu resource.Quantity
+	mem resource.Quantity
+}
+
+// effectiveRequests returns the requests the scheduler accounts for a pod,
+// the larger of the sum of its containers and its biggest init container
+// plus any pod overhead
+func effectiveRequests(pod v1.Pod) podRequests {
+	req := podRequests{}
+	for _, container := range pod.Spec.Containers {
+		req.cpu.Add(*container.Resources.Requests.Cpu())
+		req.mem.Add(*container.Resources.Requests.Memory())
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if cpu := container.Resources.Requests.Cpu(); cpu.Cmp(req.cpu) > 0 {
+			req.cpu = cpu.DeepCopy()
+		}
+		if mem := container.Resources.Requests.Memory(); mem.Cmp(req.mem) > 0 {
+			req.mem = mem.DeepCopy()
+		}
+	}
+	if overhead, ok := pod.Spec.Overhead[v1.ResourceCPU]; ok {
+		req.cpu.Add(overhead)
+	}
+	if overhead, ok := pod.Spec.Overhead[v1.ResourceMemory]; ok {
+		req.mem.Add(overhead)
+	}
+	return req
+}
+
+func nodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func toleratesTaint(tolerations []v1.Toleration, taint *v1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedCandidates(candidates map[string]*drainNode) []*drainNode {
+	ordered := make([]*drainNode, 0, len(candidates))
+	for _, c := range candidates {
+		ordered = append(ordered, c)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if c := ordered[i].cpu.Cmp(ordered[j].cpu); c != 0 {
+			return c > 0
+		}
+		return ordered[i].node.Name < ordered[j].node.Name
+	})
+	return ordered
+}
+
+func isDaemonSetPod(pod v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller && ref.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+func podKey(pod v1.Pod) string {
+	return fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+}
@@ -0,0 +1,305 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func drainNodeOf(name, cpu, mem string, pods int64) v1.Node {
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"kubernetes.io/hostname": name}},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(mem),
+				v1.ResourcePods:   *resource.NewQuantity(pods, resource.DecimalSI),
+			},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func drainPodOf(name, node, cpu, mem string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{Name: "app", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(mem),
+			}}}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func TestSimulateDrain(t *testing.T) {
+	controller := true
+	taint := v1.Taint{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule}
+	tests := []struct {
+		name  string
+		nodes func() []v1.Node
+		pods  func() []v1.Pod
+		// placed is the node of every pod that fits, unschedulable is the
+		// reason of every one that doesn't
+		placed        map[string]string
+		unschedulable map[string]string
+		skipped       []string
+		evicted       int
+	}{
+		{
+			name: "first fit decreasing",
+			nodes: func() []v1.Node {
+				return []v1.Node{drainNodeOf("a", "4", "8Gi", 110), drainNodeOf("b", "2", "4Gi", 110), drainNodeOf("c", "1", "4Gi", 110)}
+			},
+			pods: func() []v1.Pod {
+				return []v1.Pod{
+					drainPodOf("small", "a", "500m", "128Mi"),
+					drainPodOf("large", "a", "1500m", "128Mi"),
+					drainPodOf("medium", "a", "800m", "128Mi"),
+				}
+			},
+			// large goes to b with the most free cpu, medium then only fits
+			// on c and small fills what is left of b
+			placed:  map[string]string{"default/large": "b", "default/medium": "c", "default/small": "b"},
+			evicted: 3,
+		},
+		{
+			name: "fits on no node",
+			nodes: func() []v1.Node {
+				return []v1.Node{drainNodeOf("a", "8", "8Gi", 110), drainNodeOf("b", "2", "4Gi", 110)}
+			},
+			pods: func() []v1.Pod {
+				return []v1.Pod{drainPodOf("huge", "a", "4", "1Gi")}
+			},
+			unschedulable: map[string]string{"default/huge": "insufficient cpu"},
+			evicted:       1,
+		},
+		{
+			name: "pods already running on the other nodes take up room",
+			nodes: func() []v1.Node {
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), drainNodeOf("b", "2", "4Gi", 110)}
+			},
+			pods: func() []v1.Pod {
+				return []v1.Pod{drainPodOf("web", "a", "1", "1Gi"), drainPodOf("db", "b", "1", "3500Mi")}
+			},
+			unschedulable: map[string]string{"default/web": "insufficient memory"},
+			evicted:       1,
+		},
+		{
+			name: "out of pod slots",
+			nodes: func() []v1.Node {
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), drainNodeOf("b", "2", "4Gi", 1)}
+			},
+			pods: func() []v1.Pod {
+				return []v1.Pod{drainPodOf("web", "a", "100m", "128Mi"), drainPodOf("db", "b", "100m", "128Mi")}
+			},
+			unschedulable: map[string]string{"default/web": "insufficient pods"},
+			evicted:       1,
+		},
+		{
+			name: "taints and tolerations",
+			nodes: func() []v1.Node {
+				b := drainNodeOf("b", "2", "4Gi", 110)
+				b.Spec.Taints = []v1.Taint{taint}
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), b}
+			},
+			pods: func() []v1.Pod {
+				tolerating := drainPodOf("tolerating", "a", "100m", "128Mi")
+				tolerating.Spec.Tolerations = []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "gpu", Effect: v1.TaintEffectNoSchedule}}
+				return []v1.Pod{tolerating, drainPodOf("plain", "a", "100m", "128Mi")}
+			},
+			placed:        map[string]string{"default/tolerating": "b"},
+			unschedulable: map[string]string{"default/plain": "untolerated taint " + taint.ToString()},
+			evicted:       2,
+		},
+		{
+			name: "prefer no schedule taints are ignored",
+			nodes: func() []v1.Node {
+				b := drainNodeOf("b", "2", "4Gi", 110)
+				b.Spec.Taints = []v1.Taint{{Key: "spot", Effect: v1.TaintEffectPreferNoSchedule}}
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), b}
+			},
+			pods: func() []v1.Pod {
+				return []v1.Pod{drainPodOf("web", "a", "100m", "128Mi")}
+			},
+			placed:  map[string]string{"default/web": "b"},
+			evicted: 1,
+		},
+		{
+			name: "node selector",
+			nodes: func() []v1.Node {
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), drainNodeOf("b", "2", "4Gi", 110), drainNodeOf("c", "1", "4Gi", 110)}
+			},
+			pods: func() []v1.Pod {
+				pinned := drainPodOf("pinned", "a", "100m", "128Mi")
+				pinned.Spec.NodeSelector = map[string]string{"kubernetes.io/hostname": "c"}
+				lost := drainPodOf("lost", "a", "100m", "128Mi")
+				lost.Spec.NodeSelector = map[string]string{"kubernetes.io/hostname": "a"}
+				return []v1.Pod{pinned, lost}
+			},
+			placed:        map[string]string{"default/pinned": "c"},
+			unschedulable: map[string]string{"default/lost": "node selector does not match"},
+			evicted:       2,
+		},
+		{
+			name: "init containers count when they are bigger",
+			nodes: func() []v1.Node {
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), drainNodeOf("b", "1", "4Gi", 110)}
+			},
+			pods: func() []v1.Pod {
+				pod := drainPodOf("migrate", "a", "100m", "128Mi")
+				pod.Spec.InitContainers = []v1.Container{{Name: "init", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("1500m"),
+				}}}}
+				return []v1.Pod{pod}
+			},
+			unschedulable: map[string]string{"default/migrate": "insufficient cpu"},
+			evicted:       1,
+		},
+		{
+			name: "daemonset, mirror and finished pods aren't evicted",
+			nodes: func() []v1.Node {
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), drainNodeOf("b", "2", "4Gi", 110)}
+			},
+			pods: func() []v1.Pod {
+				daemon := drainPodOf("daemon", "a", "100m", "128Mi")
+				daemon.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "logs", Controller: &controller}}
+				mirror := drainPodOf("mirror", "a", "100m", "128Mi")
+				mirror.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+				done := drainPodOf("done", "a", "100m", "128Mi")
+				done.Status.Phase = v1.PodSucceeded
+				return []v1.Pod{daemon, mirror, done, drainPodOf("web", "a", "100m", "128Mi")}
+			},
+			placed:  map[string]string{"default/web": "b"},
+			skipped: []string{"default/mirror"},
+			evicted: 1,
+		},
+		{
+			name: "cordoned and not ready nodes aren't candidates",
+			nodes: func() []v1.Node {
+				cordoned := drainNodeOf("b", "2", "4Gi", 110)
+				cordoned.Spec.Unschedulable = true
+				notReady := drainNodeOf("c", "2", "4Gi", 110)
+				notReady.Status.Conditions[0].Status = v1.ConditionFalse
+				return []v1.Node{drainNodeOf("a", "2", "4Gi", 110), cordoned, notReady}
+			},
+			pods: func() []v1.Pod {
+				return []v1.Pod{drainPodOf("web", "a", "100m", "128Mi")}
+			},
+			unschedulable: map[string]string{"default/web": "no schedulable nodes"},
+			evicted:       1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := simulateDrain("a", tt.nodes(), tt.pods())
+			placed := map[string]string{}
+			for _, p := range report.Placements {
+				placed[p.Pod] = p.Node
+			}
+			unschedulable := map[string]string{}
+			for _, p := range report.Unschedulable {
+				unschedulable[p.Pod] = p.Reason
+			}
+			if tt.placed == nil {
+				tt.placed = map[string]string{}
+			}
+			if tt.unschedulable == nil {
+				tt.unschedulable = map[string]string{}
+			}
+			if !reflect.DeepEqual(placed, tt.placed) {
+				t.Errorf("expected placements %v, got %v", tt.placed, placed)
+			}
+			if !reflect.DeepEqual(unschedulable, tt.unschedulable) {
+				t.Errorf("expected unschedulable %v, got %v", tt.unschedulable, unschedulable)
+			}
+			if !reflect.DeepEqual(report.Skipped, tt.skipped) {
+				t.Errorf("expected skipped %v, got %v", tt.skipped, report.Skipped)
+			}
+			if report.Evicted != tt.evicted {
+				t.Errorf("expected %d evicted, got %d", tt.evicted, report.Evicted)
+			}
+			if report.Fits != (len(tt.unschedulable) == 0) {
+				t.Errorf("expected fits to be %v", len(tt.unschedulable) == 0)
+			}
+		})
+	}
+}
+
+func TestEffectiveRequests(t *testing.T) {
+	requests := func(cpu, mem string) v1.ResourceRequirements {
+		return v1.ResourceRequirements{Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(mem),
+		}}
+	}
+	tests := []struct {
+		name     string
+		spec     v1.PodSpec
+		cpu, mem string
+	}{
+		{
+			name: "sum of the containers",
+			spec: v1.PodSpec{Containers: []v1.Container{
+				{Resources: requests("100m", "64Mi")},
+				{Resources: requests("200m", "128Mi")},
+			}},
+			cpu: "300m", mem: "192Mi",
+		},
+		{
+			name: "biggest init container",
+			spec: v1.PodSpec{
+				Containers:     []v1.Container{{Resources: requests("100m", "64Mi")}},
+				InitContainers: []v1.Container{{Resources: requests("1", "32Mi")}, {Resources: requests("50m", "256Mi")}},
+			},
+			cpu: "1", mem: "256Mi",
+		},
+		{
+			name: "overhead",
+			spec: v1.PodSpec{
+				Containers: []v1.Container{{Resources: requests("100m", "64Mi")}},
+				Overhead: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("250m"),
+					v1.ResourceMemory: resource.MustParse("120Mi"),
+				},
+			},
+			cpu: "350m", mem: "184Mi",
+		},
+		{
+			name: "no requests",
+			spec: v1.PodSpec{Containers: []v1.Container{{}}},
+			cpu:  "0", mem: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := effectiveRequests(v1.Pod{Spec: tt.spec})
+			if want := resource.MustParse(tt.cpu); req.cpu.Cmp(want) != 0 {
+				t.Errorf("expected cpu %s, got %s", tt.cpu, req.cpu.String())
+			}
+			if want := resource.MustParse(tt.mem); req.mem.Cmp(want) != 0 {
+				t.Errorf("expected memory %s, got %s", tt.mem, req.mem.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// EvictOldest bounds the map to max entries by dropping the ones that were
+// seen least recently
+func EvictOldest[V any](m map[string]V, max int, seen func(V) time.Time) {
+	if len(m) <= max {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return seen(m[keys[i]]).Before(seen(m[keys[j]]))
+	})
+	for _, key := range keys[:len(keys)-max] {
+		delete(m, key)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// HPAOptions are the options of the hpa view
+type HPAOptions struct {
+	LabelSelector string
+	AllNamespaces bool
+}
+
+// Autoscaler is the state of a horizontal pod autoscaler
+type Autoscaler struct {
+	// Reference is the kind and name of the scale target, ie Deployment/web
+	Reference  string
+	APIVersion string
+	Kind       string
+	Target     string
+
+	MinReplicas     int32
+	MaxReplicas     int32
+	CurrentReplicas int32
+	DesiredReplicas int32
+	Metrics         []AutoscalerMetric
+
+	// the status conditions that are false, ie ScalingActive, and whether
+	// the replica count is being held at the min or max
+	Unable         []string
+	ScalingLimited bool
+}
+
+// AutoscalerMetric is one of the metrics an autoscaler scales on. The
+// values are percents when Utilization is set.
+type AutoscalerMetric struct {
+	Name        string
+	Current     string
+	Target      string
+	Utilization bool
+
+	CurrentValue float64
+	TargetValue  float64
+}
+
+// GetHPAMetrics returns the autoscalers from the informer, their status
+// already has the usage the controller last saw so nothing else is called
+func (m MetricsClient) GetHPAMetrics(ctx context.Context, o *HPAOptions) ([]MetricValue, error) {
+	selector, err := labels.Parse(o.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.cache.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	var hpas []*autoscalingv2.HorizontalPodAutoscaler
+	if m.ns == metav1.NamespaceAll {
+		hpas, err = m.cache.hpas.List(selector)
+	} else {
+		hpas, err = m.cache.hpas.HorizontalPodAutoscalers(m.ns).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := []MetricValue{}
+	for _, hpa := range hpas {
+		values = append(values, MetricValue{
+			Name:       hpa.Name,
+			Namespace:  hpa.Namespace,
+			Age:        translateTimestampSince(hpa.CreationTimestamp),
+			Autoscaler: getAutoscaler(hpa),
+		})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Key() < values[j].Key()
+	})
+	return values, nil
+}
+
+func getAutoscaler(hpa *autoscalingv2.HorizontalPodAutoscaler) *Autoscaler {
+	a := &Autoscaler{
+		Reference:       hpa.Spec.ScaleTargetRef.Kind + "/" + hpa.Spec.ScaleTargetRef.Name,
+		APIVersion:      hpa.Spec.ScaleTargetRef.APIVersion,
+		Kind:            hpa.Spec.ScaleTargetRef.Kind,
+		Target:          hpa.Spec.ScaleTargetRef.Name,
+		MinReplicas:     1,
+		MaxReplicas:     hpa.Spec.MaxReplicas,
+		CurrentReplicas: hpa.Status.CurrentReplicas,
+		DesiredReplicas: hpa.Status.DesiredReplicas,
+	}
+	if hpa.Spec.MinReplicas != nil {
+		a.MinReplicas = *hpa.Spec.MinReplicas
+	}
+	// the current metrics are in the same order as the spec, the same as
+	// kubectl describe assumes
+	for i, spec := range hpa.Spec.Metrics {
+		var status *autoscalingv2.MetricStatus
+		if i < len(hpa.Status.CurrentMetrics) {
+			status = &hpa.Status.CurrentMetrics[i]
+		}
+		a.Metrics = append(a.Metrics, autoscalerMetric(spec, status))
+	}
+	for _, c := range hpa.Status.Conditions {
+		switch {
+		case c.Type == autoscalingv2.ScalingLimited && c.Status == v1.ConditionTrue:
+			a.ScalingLimited = true
+		case c.Type != autoscalingv2.ScalingLimited && c.Status == v1.ConditionFalse:
+			a.Unable = append(a.Unable, string(c.Type))
+		}
+	}
+	return a
+}
+
+func autoscalerMetric(spec autoscalingv2.MetricSpec, status *autoscalingv2.MetricStatus) AutoscalerMetric {
+	var name string
+	var target autoscalingv2.MetricTarget
+	var current *autoscalingv2.MetricValueStatus
+	switch spec.Type {
+	case autoscalingv2.ResourceMetricSourceType:
+		name, target = string(spec.Resource.Name), spec.Resource.Target
+		if status != nil && status.Resource != nil {
+			current = &status.Resource.Current
+		}
+	case autoscalingv2.ContainerResourceMetricSourceType:
+		name = fmt.Sprintf("%s/%s", spec.ContainerResource.Container, spec.ContainerResource.Name)
+		target = spec.ContainerResource.Target
+		if status != nil && status.ContainerResource != nil {
+			current = &status.ContainerResource.Current
+		}
+	case autoscalingv2.PodsMetricSourceType:
+		name, target = spec.Pods.Metric.Name, spec.Pods.Target
+		if status != nil && status.Pods != nil {
+			current = &status.Pods.Current
+		}
+	case autoscalingv2.ObjectMetricSourceType:
+		name, target = spec.Object.Metric.Name, spec.Object.Target
+		if status != nil && status.Object != nil {
+			current = &status.Object.Current
+		}
+	case autoscalingv2.ExternalMetricSourceType:
+		name, target = spec.External.Metric.Name, spec.External.Target
+		if status != nil && status.External != nil {
+			current = &status.External.Current
+		}
+	}
+	metric := AutoscalerMetric{Name: name, Current: "<unknown>", Target: "<unknown>"}
+	switch {
+	case target.AverageUtilization != nil:
+		metric.Utilization = true
+		metric.TargetValue = float64(*target.AverageUtilization)
+		metric.Target = fmt.Sprintf("%d%%", *target.AverageUtilization)
+		if current != nil && current.AverageUtilization != nil {
+			metric.CurrentValue = float64(*current.AverageUtilization)
+			metric.Current = fmt.Sprintf("%d%%", *current.AverageUtilization)
+		}
+	case target.AverageValue != nil:
+		metric.TargetValue, metric.Target = quantity(target.AverageValue)
+		if current != nil && current.AverageValue != nil {
+			metric.CurrentValue, metric.Current = quantity(current.AverageValue)
+		}
+	case target.Value != nil:
+		metric.TargetValue, metric.Target = quantity(target.Value)
+		if current != nil && current.Value != nil {
+			metric.CurrentValue, metric.Current = quantity(current.Value)
+		}
+	}
+	return metric
+}
+
+func quantity(q *resource.Quantity) (float64, string) {
+	return q.AsApproximateFloat64(), q.String()
+}
+
+// GetHPATargetSelector returns the label selector of the pods the
+// autoscaler scales. It is read from the scale subresource of the target
+// the same way the autoscaler does so any kind that can be scaled works.
+func (m MetricsClient) GetHPATargetSelector(ctx context.Context, apiVersion, kind, name, ns string) (string, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return "", err
+	}
+	mapper, err := m.flags.ToRESTMapper()
+	if err != nil {
+		return "", err
+	}
+	mapping, err := mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		return "", err
+	}
+	prefix := "/apis/" + gv.Group + "/" + gv.Version
+	if gv.Group == "" {
+		prefix = "/api/" + gv.Version
+	}
+	data, err := m.k.CoreV1().RESTClient().Get().
+		AbsPath(prefix, "namespaces", ns, mapping.Resource.Resource, name, "scale").
+		Do(ctx).Raw()
+	if err != nil {
+		return "", err
+	}
+	var scale autoscalingv1.Scale
+	if err := json.Unmarshal(data, &scale); err != nil {
+		return "", err
+	}
+	if scale.Status.Selector == "" {
+		return "", fmt.Errorf("%s/%s has no selector in its scale subresource", kind, name)
+	}
+	return scale.Status.Selector, nil
+}
+
+func (m MetricsClient) GetHPA(ctx context.Context, name, ns string) (string, error) {
+	hpa, err := m.k.AutoscalingV2().HorizontalPodAutoscalers(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if !m.showManagedFields {
+		hpa.ManagedFields = nil
+	}
+	s, err := yaml.Marshal(hpa)
+	if err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
@@ -0,0 +1,381 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	metricsapi "k8s.io/metrics/pkg/apis/metrics"
+)
+
+// how many kubelets are scraped at the same time
+const kubeletConcurrency = 16
+
+// counter is the last reading of a cumulative cpu counter along with the
+// rate computed from the reading before it
+type counter struct {
+	value  float64
+	at     time.Time
+	rate   float64
+	window time.Duration
+	ready  bool
+}
+
+// kubeletReading is the parsed /metrics/resource output of a single node
+type kubeletReading struct {
+	node       metricsapi.NodeMetrics
+	hasNode    bool
+	containers map[string]map[string]metricsapi.ContainerMetrics
+	times      map[string]metav1.Time
+	windows    map[string]time.Duration
+
+	// warming are the pods by namespace/name and the node under its name
+	// that only have a single cpu reading so far
+	warming map[string]bool
+}
+
+// kubeletSource reads the resource metrics straight from the kubelets. The
+// cpu counters are turned into rates between the last two readings with
+// different timestamps, the kubelet only updates them at its housekeeping
+// interval so that is the resolution rather than how often topui polls.
+type kubeletSource struct {
+	client MetricsClient
+
+	// the cpu counters of every node by container, the node itself is
+	// under an empty key
+	mu       sync.Mutex
+	counters map[string]map[string]*counter
+
+	// warming is what is waiting on a second reading by node
+	warming map[string]map[string]bool
+
+	// err is why some of the nodes couldn't be read in the last scrape
+	err error
+}
+
+func newKubeletSource(m MetricsClient) *kubeletSource {
+	return &kubeletSource{
+		client:   m,
+		counters: map[string]map[string]*counter{},
+		warming:  map[string]map[string]bool{},
+	}
+}
+
+// warmingUp returns whether the pod or node only has a single cpu reading
+// so there is no rate for it yet
+func (s *kubeletSource) warmingUp(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, warming := range s.warming {
+		if warming[key] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *kubeletSource) PodMetrics(ctx context.Context, namespace string, selector labels.Selector) ([]metricsapi.PodMetrics, error) {
+	if err := s.client.cache.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	// only the nodes running the pods being watched need to be scraped
+	var pods []*v1.Pod
+	var err error
+	if namespace == metav1.NamespaceAll {
+		pods, err = s.client.cache.pods.List(selector)
+	} else {
+		pods, err = s.client.cache.pods.Pods(namespace).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	nodes := map[string]bool{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			nodes[pod.Spec.NodeName] = true
+		}
+	}
+	readings, err := s.scrapeAll(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []metricsapi.PodMetrics{}
+	for _, r := range readings {
+		for key, containers := range r.containers {
+			ns, name := splitKey(key)
+			if namespace != metav1.NamespaceAll && ns != namespace {
+				continue
+			}
+			item := metricsapi.PodMetrics{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Timestamp:  r.times[key],
+				Window:     metav1.Duration{Duration: r.windows[key]},
+			}
+			for _, c := range containers {
+				item.Containers = append(item.Containers, c)
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *kubeletSource) NodeMetrics(ctx context.Context, selector labels.Selector) ([]metricsapi.NodeMetrics, error) {
+	if err := s.client.cache.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	list, err := s.client.cache.nodes.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	nodes := map[string]bool{}
+	for _, n := range list {
+		nodes[n.Name] = true
+	}
+	readings, err := s.scrapeAll(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	items := []metricsapi.NodeMetrics{}
+	for _, r := range readings {
+		if r.hasNode {
+			items = append(items, r.node)
+		}
+	}
+	return items, nil
+}
+
+// lastErr returns why some of the nodes couldn't be read in the last scrape,
+// it is nil when all or none of them could
+func (s *kubeletSource) lastErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// scrapeAll reads the given nodes in parallel. Nodes that can't be reached
+// are left out so their pods show up without metrics.
+func (s *kubeletSource) scrapeAll(ctx context.Context, nodes map[string]bool) ([]kubeletReading, error) {
+	var mu sync.Mutex
+	readings := []kubeletReading{}
+	failed, err := eachNode(ctx, nodes, func(ctx context.Context, name string) error {
+		r, err := s.read(ctx, name)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		readings = append(readings, r)
+		return nil
+	})
+	s.mu.Lock()
+	s.err = failed
+	s.mu.Unlock()
+	return readings, err
+}
+
+// nodeErrors are the errors of the nodes that couldn't be read by name
+type nodeErrors map[string]error
+
+func (e nodeErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msg := fmt.Sprintf("kubelet on %s: %v", names[0], e[names[0]])
+	if len(names) > 1 {
+		msg += fmt.Sprintf(" (and %d more nodes)", len(names)-1)
+	}
+	return msg
+}
+
+// eachNode calls fn for every node with a bounded number running at once.
+// err is only returned when it failed for all of them, failed has the nodes
+// it failed for when some of them worked.
+func eachNode(ctx context.Context, nodes map[string]bool, fn func(ctx context.Context, name string) error) (failed error, err error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		ok      int
+		lastErr error
+	)
+	errs := nodeErrors{}
+	sem := make(chan struct{}, kubeletConcurrency)
+	for name := range nodes {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			err := fn(ctx, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = fmt.Errorf("kubelet on %s: %w", name, err)
+				errs[name] = err
+				return
+			}
+			ok++
+		}(name)
+	}
+	wg.Wait()
+	if ok == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	return nil, nil
+}
+
+// read scrapes a single node. The cpu of the node and its containers is only
+// reported once the kubelet has updated the counters since the first read,
+// until then they are warming up.
+func (s *kubeletSource) read(ctx context.Context, node string) (kubeletReading, error) {
+	data, err := s.client.k.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", node, "proxy", "metrics", "resource").
+		Do(ctx).Raw()
+	if err != nil {
+		return kubeletReading{}, err
+	}
+	samples, err := parsePromText(data)
+	if err != nil {
+		return kubeletReading{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// counters of containers that are gone are dropped with the old map
+	previous := s.counters[node]
+	current := map[string]*counter{}
+	s.counters[node] = current
+	update := func(key string, sample promSample) counter {
+		c, ok := previous[key]
+		if !ok {
+			c = &counter{}
+		}
+		current[key] = c
+		return c.update(sample, ok)
+	}
+	r := kubeletReading{
+		node:       metricsapi.NodeMetrics{ObjectMeta: metav1.ObjectMeta{Name: node}, Usage: v1.ResourceList{}},
+		containers: map[string]map[string]metricsapi.ContainerMetrics{},
+		times:      map[string]metav1.Time{},
+		windows:    map[string]time.Duration{},
+		warming:    map[string]bool{},
+	}
+	container := func(sample promSample) (string, metricsapi.ContainerMetrics) {
+		key := sample.labels["namespace"] + "/" + sample.labels["pod"]
+		if r.containers[key] == nil {
+			r.containers[key] = map[string]metricsapi.ContainerMetrics{}
+		}
+		c, ok := r.containers[key][sample.labels["container"]]
+		if !ok {
+			c = metricsapi.ContainerMetrics{Name: sample.labels["container"], Usage: v1.ResourceList{}}
+		}
+		return key, c
+	}
+	for _, sample := range samples {
+		switch sample.name {
+		case "node_cpu_usage_seconds_total":
+			c := update("", sample)
+			if !c.ready {
+				r.warming[node] = true
+				continue
+			}
+			r.hasNode = true
+			r.node.Usage[v1.ResourceCPU] = *resource.NewMilliQuantity(int64(c.rate*1000), resource.DecimalSI)
+			r.node.Timestamp = metav1.NewTime(c.at)
+			r.node.Window = metav1.Duration{Duration: c.window}
+		case "node_memory_working_set_bytes":
+			r.node.Usage[v1.ResourceMemory] = *resource.NewQuantity(int64(sample.value), resource.BinarySI)
+		case "container_cpu_usage_seconds_total":
+			key, c := container(sample)
+			cpu := update(key+"/"+c.Name, sample)
+			if !cpu.ready {
+				r.warming[key] = true
+				continue
+			}
+			c.Usage[v1.ResourceCPU] = *resource.NewMilliQuantity(int64(cpu.rate*1000), resource.DecimalSI)
+			r.containers[key][c.Name] = c
+			r.times[key] = metav1.NewTime(cpu.at)
+			r.windows[key] = cpu.window
+		case "container_memory_working_set_bytes":
+			key, c := container(sample)
+			c.Usage[v1.ResourceMemory] = *resource.NewQuantity(int64(sample.value), resource.BinarySI)
+			r.containers[key][c.Name] = c
+		}
+	}
+	// a node without a cpu rate yet isn't reported at all, same for the
+	// pods that only have memory so far
+	for key, containers := range r.containers {
+		if _, ok := r.times[key]; !ok {
+			r.warming[key] = true
+			delete(r.containers, key)
+			continue
+		}
+		delete(r.warming, key)
+		for name, c := range containers {
+			if _, ok := c.Usage[v1.ResourceCPU]; !ok {
+				c.Usage[v1.ResourceCPU] = *resource.NewMilliQuantity(0, resource.DecimalSI)
+				containers[name] = c
+			}
+		}
+	}
+	s.warming[node] = r.warming
+	return r, nil
+}
+
+// update stores a reading of the counter. The rate is only recomputed when
+// the kubelet has a newer reading, otherwise the last rate is kept with its
+// timestamp so the same sample isn't counted twice. A counter isn't ready
+// until it has seen two different timestamps.
+func (c *counter) update(sample promSample, seen bool) counter {
+	at := sample.timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if seen && at.After(c.at) && sample.value >= c.value {
+		c.window = at.Sub(c.at)
+		c.rate = (sample.value - c.value) / c.window.Seconds()
+		c.ready = true
+	} else if sample.value < c.value {
+		// the container restarted, start over
+		c.ready = false
+	}
+	c.value = sample.value
+	c.at = at
+	return *c
+}
+
+func splitKey(key string) (string, string) {
+	for i := range key {
+		if key[i] == '/' {
+			return key[:i], key[i+1:]
+		}
+	}
+	return "", key
+}
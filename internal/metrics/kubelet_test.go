@@ -0,0 +1,206 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const resourceMetrics = `# TYPE container_cpu_usage_seconds_total counter
+container_cpu_usage_seconds_total{container="app",namespace="default",pod="web"} %v %d
+# TYPE container_memory_working_set_bytes gauge
+container_memory_working_set_bytes{container="app",namespace="default",pod="web"} 1.048576e+08 %d
+# TYPE node_cpu_usage_seconds_total counter
+node_cpu_usage_seconds_total %v %d
+# TYPE node_memory_working_set_bytes gauge
+node_memory_working_set_bytes 2.147483648e+09 %d
+`
+
+// kubeletServer serves the readings one after the other as the
+// /metrics/resource of node-1 and keeps serving the last one
+func kubeletServer(t *testing.T, readings ...string) *kubeletSource {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes/node-1/proxy/metrics/resource" {
+			http.NotFound(w, r)
+			return
+		}
+		reading := readings[len(readings)-1]
+		if calls < len(readings) {
+			reading = readings[calls]
+		}
+		calls++
+		fmt.Fprint(w, reading)
+	}))
+	t.Cleanup(srv.Close)
+	k, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newKubeletSource(MetricsClient{k: k})
+}
+
+func reading(containerCPU, nodeCPU float64, at time.Time) string {
+	ms := at.UnixMilli()
+	return fmt.Sprintf(resourceMetrics, containerCPU, ms, ms, nodeCPU, ms, ms)
+}
+
+func TestKubeletRates(t *testing.T) {
+	start := time.UnixMilli(1700000000000)
+	s := kubeletServer(t,
+		reading(10, 100, start),
+		// the kubelet hasn't done its housekeeping yet
+		reading(10, 100, start),
+		reading(12, 110, start.Add(10*time.Second)),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		r, err := s.read(ctx, "node-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.hasNode || len(r.containers) != 0 {
+			t.Fatalf("read %d: expected no usage before a second timestamp, got %+v", i, r)
+		}
+		if !s.warmingUp("node-1") || !s.warmingUp("default/web") {
+			t.Fatalf("read %d: expected the node and pod to be warming up", i)
+		}
+	}
+
+	r, err := s.read(ctx, "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.warmingUp("node-1") || s.warmingUp("default/web") {
+		t.Error("expected the node and pod to be warmed up")
+	}
+	if !r.hasNode {
+		t.Fatal("expected node usage")
+	}
+	if cpu := r.node.Usage[v1.ResourceCPU]; cpu.MilliValue() != 1000 {
+		t.Errorf("node cpu = %dm, want 1000m", cpu.MilliValue())
+	}
+	if r.node.Window.Duration != 10*time.Second {
+		t.Errorf("node window = %s, want 10s", r.node.Window.Duration)
+	}
+	c, ok := r.containers["default/web"]["app"]
+	if !ok {
+		t.Fatal("expected container usage")
+	}
+	if cpu := c.Usage[v1.ResourceCPU]; cpu.MilliValue() != 200 {
+		t.Errorf("container cpu = %dm, want 200m", cpu.MilliValue())
+	}
+	if mem := c.Usage[v1.ResourceMemory]; mem.Value() != 100*1024*1024 {
+		t.Errorf("container memory = %d, want %d", mem.Value(), 100*1024*1024)
+	}
+	if got := r.times["default/web"].Time; !got.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("container timestamp = %s, want %s", got, start.Add(10*time.Second))
+	}
+}
+
+func TestCounterUpdate(t *testing.T) {
+	start := time.UnixMilli(1700000000000)
+	c := &counter{}
+	if got := c.update(promSample{value: 5, timestamp: start}, false); got.ready {
+		t.Fatal("a single reading can't have a rate")
+	}
+	if got := c.update(promSample{value: 5, timestamp: start}, true); got.ready {
+		t.Fatal("the same timestamp can't have a rate")
+	}
+	got := c.update(promSample{value: 8, timestamp: start.Add(2 * time.Second)}, true)
+	if !got.ready || got.rate != 1.5 || got.window != 2*time.Second {
+		t.Errorf("got rate %v over %s, want 1.5 over 2s", got.rate, got.window)
+	}
+	// the same sample again keeps the last rate
+	got = c.update(promSample{value: 8, timestamp: start.Add(2 * time.Second)}, true)
+	if !got.ready || got.rate != 1.5 {
+		t.Errorf("got rate %v, want the previous 1.5", got.rate)
+	}
+	// a lower value means the container restarted
+	if got := c.update(promSample{value: 1, timestamp: start.Add(4 * time.Second)}, true); got.ready {
+		t.Error("expected a restarted counter to start over")
+	}
+}
+
+func TestEachNode(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name   string
+		nodes  []string
+		fail   map[string]bool
+		failed string
+		err    string
+	}{
+		{name: "all read", nodes: []string{"a", "b"}},
+		{
+			name:   "some failed",
+			nodes:  []string{"a", "b", "c"},
+			fail:   map[string]bool{"b": true, "c": true},
+			failed: "kubelet on b: connection refused (and 1 more nodes)",
+		},
+		{
+			name:   "one failed",
+			nodes:  []string{"a", "b"},
+			fail:   map[string]bool{"b": true},
+			failed: "kubelet on b: connection refused",
+		},
+		{
+			name:  "all failed",
+			nodes: []string{"a"},
+			fail:  map[string]bool{"a": true},
+			err:   "kubelet on a: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := map[string]bool{}
+			for _, n := range tt.nodes {
+				nodes[n] = true
+			}
+			failed, err := eachNode(context.Background(), nodes, func(_ context.Context, name string) error {
+				if tt.fail[name] {
+					return down
+				}
+				return nil
+			})
+			if got := errString(failed); got != tt.failed {
+				t.Errorf("expected failed %q, got %q", tt.failed, got)
+			}
+			if got := errString(err); got != tt.err {
+				t.Errorf("expected error %q, got %q", tt.err, got)
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,37 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/pager"
+)
+
+// listFunc is the List method of a typed client wrapped to return a
+// runtime.Object
+type listFunc func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error)
+
+// eachListItem lists the objects a chunk at a time using limit/continue
+// instead of a single unbounded call and calls fn with each item. It is only
+// used for the one off lists, the informers do their own paging.
+func (m MetricsClient) eachListItem(ctx context.Context, opts metav1.ListOptions, list listFunc, fn func(obj runtime.Object) error) error {
+	p := pager.New(pager.ListPageFunc(list))
+	p.PageSize = m.chunkSize
+	return p.EachListItem(ctx, opts, fn)
+}
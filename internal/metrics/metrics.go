@@ -16,13 +16,15 @@ limitations under the License.
 package metrics
 
 import (
-	"log"
 	"strings"
+	"time"
 
+	"github.com/chriskim06/kubectl-topui/internal/config"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
 )
@@ -36,6 +38,8 @@ func (r Resource) LowerCase() string {
 const (
 	POD  Resource = "PODS"
 	NODE Resource = "NODES"
+	PVC  Resource = "PVCS"
+	HPA  Resource = "HPAS"
 
 	DIVISOR int64 = 1024 * 1024
 )
@@ -52,6 +56,18 @@ type MetricValue struct {
 	MemLimit   int64
 	Timestamp  metav1.Time
 
+	// CPURequest and MemRequest are the sum of the container requests of a
+	// pod, the memory is in MiB like MemLimit
+	CPURequest resource.Quantity
+	MemRequest int64
+
+	// Window is the interval metrics-server averaged the usage over
+	Window time.Duration
+
+	// MetricsReason is set when the metrics api has no usage for the
+	// object and explains why, ie "metrics not available yet"
+	MetricsReason string
+
 	Namespace string
 	Node      string
 	Status    string
@@ -59,44 +75,266 @@ type MetricValue struct {
 	Restarts  int
 	Ready     int
 	Total     int
+
+	// LastTermination is the reason and time of the most recent
+	// container termination in a pod
+	LastTermination string
+
+	// Workload is the kind and name of what created a pod, ie
+	// Deployment/web
+	Workload string
+
+	// Cost is only set for pods when pricing is configured
+	Cost *Cost
+
+	// LimitRangeDefaults is what a LimitRange filled in for the containers
+	// of a pod that didn't set their own requests or limits
+	LimitRangeDefaults string
+
+	NodeInfo *NodeInfo
+
+	// EphemeralLimit is the sum of the ephemeral-storage limits of the
+	// containers in a pod in bytes
+	EphemeralLimit int64
+
+	// Stats are only set when the summary api is being read
+	Stats *Stats
+
+	// Volume is only set for persistent volume claims
+	Volume *Volume
+
+	// Throttling is only set for pods when cadvisor is being read
+	Throttling *Throttling
+
+	// Custom are the values of the configured custom and external metrics
+	// by their name
+	Custom map[string]float64
+
+	// Autoscaler is only set for horizontal pod autoscalers
+	Autoscaler *Autoscaler
+}
+
+// Key identifies the pod or node the value belongs to
+func (m MetricValue) Key() string {
+	if m.Namespace != "" {
+		return m.Namespace + "/" + m.Name
+	}
+	return m.Name
+}
+
+// NodeInfo holds the health and placement details of a node that aren't
+// part of the metrics api
+type NodeInfo struct {
+	// Status is Ready, NotReady or Unknown based on the Ready condition
+	Status         string
+	Pressure       []string
+	Unschedulable  bool
+	Taints         int
+	KubeletVersion string
+	OS             string
+	Arch           string
+	InstanceType   string
+	Zone           string
+}
+
+// Volume is the usage of a persistent volume claim from the kubelet that
+// has it mounted, the sizes are in bytes
+type Volume struct {
+	Capacity   int64
+	Used       int64
+	Available  int64
+	Inodes     int64
+	InodesUsed int64
+
+	// Pods are the names of the pods that mount the claim
+	Pods         []string
+	StorageClass string
+}
+
+// UsedPercent returns how full the volume is by bytes
+func (v Volume) UsedPercent() float64 {
+	if v.Capacity == 0 {
+		return 0
+	}
+	return float64(v.Used) / float64(v.Capacity) * 100
+}
+
+// InodesPercent returns how many of the inodes are in use
+func (v Volume) InodesPercent() float64 {
+	if v.Inodes == 0 {
+		return 0
+	}
+	return float64(v.InodesUsed) / float64(v.Inodes) * 100
+}
+
+// Healthy returns whether the node is ready and has no pressure conditions
+func (n NodeInfo) Healthy() bool {
+	return n.Status == "Ready" && len(n.Pressure) == 0
 }
 
 type MetricsClient struct {
-	k     *kubernetes.Clientset
-	m     *metricsclientset.Clientset
-	flags *genericclioptions.ConfigFlags
-	ns    string
+	k       *kubernetes.Clientset
+	m       *metricsclientset.Clientset
+	flags   *genericclioptions.ConfigFlags
+	ns      string
+	context string
+	cache   *cache
+	source  Source
+	stats   *statsCollector
+
+	throttle *throttleCollector
+	custom   *customCollector
+	pricer   *pricer
+	// meta only reads the metadata of objects, the node labels for pricing
+	meta metadata.Interface
+
+	// the page size used by eachListItem, 0 disables chunking. The
+	// informers started by Watch do their own listing and ignore it.
+	chunkSize int64
 
 	showManagedFields bool
 }
 
-func New(flags *genericclioptions.ConfigFlags, showManagedFields bool, allNs *bool) MetricsClient {
+// Options configure how the client gets the metrics
+type Options struct {
+	ShowManagedFields bool
+	AllNamespaces     *bool
+	// Namespace overrides the namespace from the flags and kubeconfig
+	Namespace string
+	// ChunkSize is the page size used when listing metrics, quotas and the
+	// objects for a drain, 0 disables chunking
+	ChunkSize int64
+	// Source is one of Sources
+	Source     string
+	Prometheus config.Prometheus
+	// Stats reads the kubelet summary api for network and filesystem usage
+	Stats bool
+	// Throttling reads the cfs counters of the pods from cadvisor
+	Throttling bool
+	// CustomMetrics are read from the custom and external metrics apis
+	CustomMetrics []config.CustomMetric
+	// Timeout bounds the requests of the clients that don't take a context
+	Timeout time.Duration
+	// Pricing estimates what the pods cost when it is enabled
+	Pricing config.Pricing
+}
+
+// New creates the clients for the cluster from the flags
+func New(flags *genericclioptions.ConfigFlags, opts Options) (MetricsClient, error) {
 	matchVersionKubeConfigFlags := cmdutil.NewMatchVersionFlags(flags)
 	f := cmdutil.NewFactory(matchVersionKubeConfigFlags)
 	k, m, err := clientSets(f)
 	if err != nil {
-		log.Fatal(err)
+		return MetricsClient{}, err
 	}
 	var namespace string
 	namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
 	if err != nil {
-		log.Fatal(err)
+		return MetricsClient{}, err
 	}
 	if flags.Namespace != nil && *flags.Namespace != "" {
 		namespace = *flags.Namespace
-	} else if allNs != nil && *allNs {
+	} else if opts.AllNamespaces != nil && *opts.AllNamespaces {
 		namespace = metav1.NamespaceAll
 	}
-	return MetricsClient{
-		k:     k,
-		m:     m,
-		flags: flags,
-		ns:    namespace,
+	if opts.Namespace != "" {
+		namespace = opts.Namespace
+	}
+	var context string
+	if flags.Context != nil && *flags.Context != "" {
+		context = *flags.Context
+	} else if raw, err := f.ToRawKubeConfigLoader().RawConfig(); err == nil {
+		context = raw.CurrentContext
+	}
+	client := MetricsClient{
+		k:       k,
+		m:       m,
+		flags:   flags,
+		ns:      namespace,
+		context: context,
+		cache:   newCache(),
+
+		chunkSize: opts.ChunkSize,
+
+		showManagedFields: opts.ShowManagedFields,
+	}
+	if opts.Stats {
+		client.stats = newStatsCollector(k)
+	}
+	if opts.Throttling {
+		client.throttle = newThrottleCollector(k)
+	}
+	if len(opts.CustomMetrics) > 0 {
+		client.custom, err = newCustomCollector(f, opts.CustomMetrics, opts.Timeout)
+		if err != nil {
+			return MetricsClient{}, err
+		}
+	}
+	if opts.Pricing.Enabled() {
+		client.pricer, err = newPricer(opts.Pricing)
+		if err != nil {
+			return MetricsClient{}, err
+		}
+		restConfig, err := f.ToRESTConfig()
+		if err != nil {
+			return MetricsClient{}, err
+		}
+		client.meta, err = metadata.NewForConfig(restConfig)
+		if err != nil {
+			return MetricsClient{}, err
+		}
+	}
+	client.source = newSource(opts, client)
+	return client, nil
+}
+
+// CanBackfill returns whether the source keeps history that the graphs can
+// be started with
+func (m MetricsClient) CanBackfill() bool {
+	_, ok := m.source.(Backfiller)
+	return ok
+}
+
+// Warnings returns why the optional data couldn't be read in the last
+// refresh by what it is for, the ones that could are nil
+func (m MetricsClient) Warnings() map[string]error {
+	warnings := map[string]error{}
+	if m.stats != nil {
+		warnings["summary"] = m.stats.lastErr()
+	}
+	if m.throttle != nil {
+		warnings["cadvisor"] = m.throttle.lastErr()
+	}
+	if s, ok := m.source.(*kubeletSource); ok {
+		warnings["kubelet"] = s.lastErr()
+	}
+	if m.custom != nil {
+		for name, err := range m.custom.lastErrs() {
+			warnings["metric "+name] = err
+		}
+	}
+	return warnings
+}
 
-		showManagedFields: showManagedFields,
+// FocusThrottling sets the node whose cadvisor metrics are read for the
+// throttling, only the pods on it get any
+func (m MetricsClient) FocusThrottling(node string) {
+	if m.throttle != nil {
+		m.throttle.setFocus(node)
 	}
 }
 
+// Context returns the name of the kubeconfig context being used
+func (m MetricsClient) Context() string {
+	return m.context
+}
+
+// Namespace returns the namespace being watched, an empty string means
+// all namespaces
+func (m MetricsClient) Namespace() string {
+	return m.ns
+}
+
 func clientSets(f cmdutil.Factory) (*kubernetes.Clientset, *metricsclientset.Clientset, error) {
 	var err error
 	config, err := f.ToRESTConfig()
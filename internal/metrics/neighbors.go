@@ -0,0 +1,215 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// the pods that together make up this much of a node's growth are the
+// ones called out as causing it
+const culpritShare = 0.8
+
+// NodeUsage is the usage of a node and of every pod on it at one time. The
+// cpu is in millicores and the memory in MiB.
+type NodeUsage struct {
+	Node string
+	Time time.Time
+	CPU  int64
+	Mem  int64
+	Pods map[string]PodUsage
+}
+
+// PodUsage is the usage of a pod on a node
+type PodUsage struct {
+	Namespace string
+	Name      string
+	CPU       int64
+	Mem       int64
+}
+
+// GetNodeUsage reads the usage of the node and its pods from the kubelet
+// summary api, it is one call however many pods the node has
+func (m MetricsClient) GetNodeUsage(ctx context.Context, node string) (NodeUsage, error) {
+	s, err := getSummary(ctx, m.k, node)
+	if err != nil {
+		return NodeUsage{}, err
+	}
+	usage := NodeUsage{Node: node, Time: time.Now(), Pods: map[string]PodUsage{}}
+	usage.CPU, usage.Mem = cpuMillis(s.Node.CPU), memMiB(s.Node.Memory)
+	if s.Node.CPU != nil && !s.Node.CPU.Time.IsZero() {
+		usage.Time = s.Node.CPU.Time
+	}
+	for _, pod := range s.Pods {
+		p := PodUsage{
+			Namespace: pod.PodRef.Namespace,
+			Name:      pod.PodRef.Name,
+			CPU:       cpuMillis(pod.CPU),
+			Mem:       memMiB(pod.Memory),
+		}
+		usage.Pods[p.Namespace+"/"+p.Name] = p
+	}
+	return usage, nil
+}
+
+func cpuMillis(c *cpuStats) int64 {
+	if c == nil {
+		return 0
+	}
+	return value(c.UsageNanoCores) / 1000000
+}
+
+func memMiB(m *memoryStats) int64 {
+	if m == nil {
+		return 0
+	}
+	return value(m.WorkingSetBytes) / DIVISOR
+}
+
+// Neighbor is how much of a node's usage and of its growth over the window
+// comes from one of its pods. The shares are percents.
+type Neighbor struct {
+	Namespace string
+	Name      string
+	CPU       int64
+	Mem       int64
+	CPUShare  float64
+	MemShare  float64
+	CPUGrowth int64
+	MemGrowth int64
+	// Culprit is set for the pods that make up most of the node's growth
+	Culprit bool
+}
+
+// NeighborReport ranks the pods on a node. Since is how far back the
+// growth goes, it is zero until there are two readings.
+type NeighborReport struct {
+	Node      string
+	CPU       int64
+	Mem       int64
+	CPUGrowth int64
+	MemGrowth int64
+	Since     time.Duration
+	// ByShare has the pods using the most of the node first and ByGrowth
+	// the ones that grew the most first
+	ByShare  []Neighbor
+	ByGrowth []Neighbor
+}
+
+// NeighborTracker keeps the recent readings of the nodes
+type NeighborTracker struct {
+	window   time.Duration
+	readings map[string][]NodeUsage
+}
+
+func NewNeighborTracker(window time.Duration) *NeighborTracker {
+	return &NeighborTracker{window: window, readings: map[string][]NodeUsage{}}
+}
+
+// Add records a reading, the ones older than the window are dropped
+func (t *NeighborTracker) Add(u NodeUsage) {
+	readings := append(t.readings[u.Node], u)
+	i := 0
+	for i < len(readings)-1 && u.Time.Sub(readings[i].Time) > t.window {
+		i++
+	}
+	t.readings[u.Node] = readings[i:]
+}
+
+// Report compares the latest reading of the node to the oldest one in the
+// window. Pods that weren't on the node back then grew from nothing.
+func (t *NeighborTracker) Report(node string) (NeighborReport, bool) {
+	readings := t.readings[node]
+	if len(readings) == 0 {
+		return NeighborReport{}, false
+	}
+	first, last := readings[0], readings[len(readings)-1]
+	r := NeighborReport{
+		Node:      node,
+		CPU:       last.CPU,
+		Mem:       last.Mem,
+		CPUGrowth: last.CPU - first.CPU,
+		MemGrowth: last.Mem - first.Mem,
+		Since:     last.Time.Sub(first.Time),
+	}
+	pods := make([]Neighbor, 0, len(last.Pods))
+	for key, p := range last.Pods {
+		n := Neighbor{Namespace: p.Namespace, Name: p.Name, CPU: p.CPU, Mem: p.Mem}
+		if r.CPU > 0 {
+			n.CPUShare = float64(p.CPU) / float64(r.CPU) * 100
+		}
+		if r.Mem > 0 {
+			n.MemShare = float64(p.Mem) / float64(r.Mem) * 100
+		}
+		if len(readings) > 1 {
+			before := first.Pods[key]
+			n.CPUGrowth, n.MemGrowth = p.CPU-before.CPU, p.Mem-before.Mem
+		}
+		pods = append(pods, n)
+	}
+	markCulprits(pods, r.CPUGrowth, func(n Neighbor) int64 { return n.CPUGrowth })
+	markCulprits(pods, r.MemGrowth, func(n Neighbor) int64 { return n.MemGrowth })
+
+	r.ByShare = append([]Neighbor{}, pods...)
+	sort.Slice(r.ByShare, func(i, j int) bool {
+		a, b := r.ByShare[i], r.ByShare[j]
+		if a.CPUShare != b.CPUShare {
+			return a.CPUShare > b.CPUShare
+		}
+		if a.MemShare != b.MemShare {
+			return a.MemShare > b.MemShare
+		}
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	r.ByGrowth = append([]Neighbor{}, pods...)
+	sort.Slice(r.ByGrowth, func(i, j int) bool {
+		a, b := r.ByGrowth[i], r.ByGrowth[j]
+		if a.CPUGrowth != b.CPUGrowth {
+			return a.CPUGrowth > b.CPUGrowth
+		}
+		if a.MemGrowth != b.MemGrowth {
+			return a.MemGrowth > b.MemGrowth
+		}
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	return r, true
+}
+
+// markCulprits marks the pods that grew the most until they add up to most
+// of the node's growth
+func markCulprits(pods []Neighbor, total int64, growth func(Neighbor) int64) {
+	if total <= 0 {
+		return
+	}
+	order := make([]int, len(pods))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return growth(pods[order[i]]) > growth(pods[order[j]])
+	})
+	var sum int64
+	for _, i := range order {
+		g := growth(pods[i])
+		if g <= 0 || float64(sum) >= float64(total)*culpritShare {
+			return
+		}
+		pods[i].Culprit = true
+		sum += g
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,58 +28,127 @@ import (
 	"k8s.io/kubectl/pkg/cmd/top"
 	"k8s.io/kubectl/pkg/metricsutil"
 	metricsapi "k8s.io/metrics/pkg/apis/metrics"
-	metricsV1beta1api "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	"sigs.k8s.io/yaml"
 )
 
 // GetNodeMetrics returns a slice of objects that are meant to be easily
-// consumable by the various termui widgets
-func (m MetricsClient) GetNodeMetrics(o *top.TopNodeOptions) ([]MetricValue, error) {
+// consumable by the various termui widgets. Only the metrics source is
+// called, the node metadata comes from the informer started by Watch.
+func (m MetricsClient) GetNodeMetrics(ctx context.Context, o *top.TopNodeOptions) ([]MetricValue, error) {
 	o.MetricsClient = m.m
 	o.NodeClient = m.k.CoreV1()
 	o.Printer = metricsutil.NewTopCmdPrinter(o.Out)
 	if o.SortBy != "" && o.SortBy != "cpu" && o.SortBy != "memory" {
 		return nil, errors.New(fmt.Sprintf("invalid sort-by provided: %s", o.SortBy))
 	}
+	selector, err := nodeSelector(o)
+	if err != nil {
+		return nil, err
+	}
 
-	mc := o.MetricsClient.MetricsV1beta1()
-	nm := mc.NodeMetricses()
+	items, err := m.source.NodeMetrics(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.setNodeMetrics(items)
 
-	// handle getting all or with resource name
-	versionedMetrics, err := nm.List(context.TODO(), metav1.ListOptions{LabelSelector: labels.Everything().String()})
+	values, err := m.nodeValues(ctx, o, selector, items)
 	if err != nil {
 		return nil, err
 	}
-	metrics := &metricsapi.NodeMetricsList{}
-	err = metricsV1beta1api.Convert_v1beta1_NodeMetricsList_To_metrics_NodeMetricsList(versionedMetrics, metrics, nil)
+	if m.stats != nil {
+		m.stats.collect(ctx, values)
+	}
+	if m.custom != nil {
+		m.custom.collect(ctx, values)
+	}
+	return values, nil
+}
+
+// CachedNodeMetrics rebuilds the values from the last response of the
+// metrics api and the current state of the node informer
+func (m MetricsClient) CachedNodeMetrics(ctx context.Context, o *top.TopNodeOptions) ([]MetricValue, error) {
+	selector, err := nodeSelector(o)
 	if err != nil {
 		return nil, err
 	}
+	values, err := m.nodeValues(ctx, o, selector, m.cache.getNodeMetrics())
+	if err != nil {
+		return nil, err
+	}
+	if m.stats != nil {
+		m.stats.apply(values)
+	}
+	if m.custom != nil {
+		m.custom.apply(values)
+	}
+	return values, nil
+}
 
+// GetNodeHistory returns the values of the nodes at each step between start
+// and end, oldest first. It is empty when the source doesn't keep history.
+func (m MetricsClient) GetNodeHistory(ctx context.Context, o *top.TopNodeOptions, start, end time.Time, step time.Duration) ([][]MetricValue, error) {
+	b, ok := m.source.(Backfiller)
+	if !ok {
+		return nil, nil
+	}
+	selector, err := nodeSelector(o)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := b.NodeHistory(ctx, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	history := [][]MetricValue{}
+	for _, items := range steps {
+		values, err := m.nodeValues(ctx, o, selector, items)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, values)
+	}
+	return history, nil
+}
+
+func nodeSelector(o *top.TopNodeOptions) (labels.Selector, error) {
+	if len(o.Selector) > 0 {
+		return labels.Parse(o.Selector)
+	}
+	return labels.Everything(), nil
+}
+
+func (m MetricsClient) nodeValues(ctx context.Context, o *top.TopNodeOptions, selector labels.Selector, items []metricsapi.NodeMetrics) ([]MetricValue, error) {
+	if err := m.cache.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	items = append([]metricsapi.NodeMetrics{}, items...)
 	if o.SortBy != "" {
-		sort.Sort(metricsutil.NewNodeMetricsSorter(metrics.Items, o.SortBy))
+		sort.Sort(metricsutil.NewNodeMetricsSorter(items, o.SortBy))
 	}
 
-	nodeList, err := o.NodeClient.Nodes().List(context.TODO(), metav1.ListOptions{
-		LabelSelector: labels.Everything().String(),
-	})
+	nodeList, err := m.cache.nodes.List(selector)
 	if err != nil {
 		return nil, err
 	}
-	var nodes []v1.Node
-	nodes = append(nodes, nodeList.Items...)
-	allocatable := make(map[string]v1.ResourceList)
-	for _, n := range nodes {
-		allocatable[n.Name] = n.Status.Allocatable
+	nodes := make(map[string]v1.Node)
+	for _, n := range nodeList {
+		nodes[n.Name] = *n
 	}
 
 	values := []MetricValue{}
-	for _, m := range metrics.Items {
+	reported := map[string]bool{}
+	for _, m := range items {
+		node, ok := nodes[m.Name]
+		if !ok {
+			continue
+		}
+		reported[m.Name] = true
 		cpuQuantity := m.Usage[v1.ResourceCPU]
-		cpuAvailable := allocatable[m.Name][v1.ResourceCPU]
+		cpuAvailable := node.Status.Allocatable[v1.ResourceCPU]
 		cpuFraction := float64(cpuQuantity.MilliValue()) / float64(cpuAvailable.MilliValue()) * 100
 		memQuantity := m.Usage[v1.ResourceMemory]
-		memAvailable := allocatable[m.Name][v1.ResourceMemory]
+		memAvailable := node.Status.Allocatable[v1.ResourceMemory]
 		memFraction := float64(memQuantity.MilliValue()) / float64(memAvailable.MilliValue()) * 100
 		values = append(values, MetricValue{
 			Name:       m.Name,
@@ -88,8 +158,26 @@ func (m MetricsClient) GetNodeMetrics(o *top.TopNodeOptions) ([]MetricValue, err
 			MemCores:   memQuantity.Value() / DIVISOR,
 			MemLimit:   memAvailable.Value() / DIVISOR,
 			MemPercent: memFraction,
+			Timestamp:  m.Timestamp,
+			Window:     m.Window.Duration,
+			NodeInfo:   getNodeInfo(node),
 		})
 	}
+	// nodes the source has no usage for yet are still listed with the reason
+	if w, ok := m.source.(warmer); ok {
+		for _, node := range nodeList {
+			if reported[node.Name] || !w.warmingUp(node.Name) {
+				continue
+			}
+			values = append(values, MetricValue{
+				Name:          node.Name,
+				CPULimit:      node.Status.Allocatable[v1.ResourceCPU],
+				MemLimit:      node.Status.Allocatable.Memory().Value() / DIVISOR,
+				NodeInfo:      getNodeInfo(*node),
+				MetricsReason: "warming up",
+			})
+		}
+	}
 
 	if o.SortBy == "" {
 		// Sort the metrics alphabetically
@@ -101,8 +189,8 @@ func (m MetricsClient) GetNodeMetrics(o *top.TopNodeOptions) ([]MetricValue, err
 	return values, nil
 }
 
-func (m MetricsClient) GetNode(name string) (string, error) {
-	node, err := m.k.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+func (m MetricsClient) GetNode(ctx context.Context, name string) (string, error) {
+	node, err := m.k.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
@@ -115,3 +203,40 @@ func (m MetricsClient) GetNode(name string) (string, error) {
 	}
 	return string(s), nil
 }
+
+func getNodeInfo(node v1.Node) *NodeInfo {
+	info := &NodeInfo{
+		Status:         "Unknown",
+		Unschedulable:  node.Spec.Unschedulable,
+		Taints:         len(node.Spec.Taints),
+		KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+		OS:             node.Status.NodeInfo.OperatingSystem,
+		Arch:           node.Status.NodeInfo.Architecture,
+		InstanceType:   firstLabel(node.Labels, v1.LabelInstanceTypeStable, v1.LabelInstanceType),
+		Zone:           firstLabel(node.Labels, v1.LabelTopologyZone, v1.LabelFailureDomainBetaZone),
+	}
+	for _, condition := range node.Status.Conditions {
+		switch condition.Type {
+		case v1.NodeReady:
+			if condition.Status == v1.ConditionTrue {
+				info.Status = "Ready"
+			} else if condition.Status == v1.ConditionFalse {
+				info.Status = "NotReady"
+			}
+		case v1.NodeMemoryPressure, v1.NodeDiskPressure, v1.NodePIDPressure:
+			if condition.Status == v1.ConditionTrue {
+				info.Pressure = append(info.Pressure, string(condition.Type))
+			}
+		}
+	}
+	return info
+}
+
+func firstLabel(l map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := l[key]; ok {
+			return v
+		}
+	}
+	return ""
+}
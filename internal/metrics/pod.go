@@ -29,7 +29,6 @@ import (
 	"k8s.io/kubectl/pkg/cmd/top"
 	"k8s.io/kubectl/pkg/metricsutil"
 	metricsapi "k8s.io/metrics/pkg/apis/metrics"
-	metricsv1beta1api "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	"sigs.k8s.io/yaml"
 )
 
@@ -40,11 +39,14 @@ type resourceLimits struct {
 	memLimit   resource.Quantity
 	cpuRequest resource.Quantity
 	memRequest resource.Quantity
+
+	ephemeralLimit resource.Quantity
 }
 
 // GetPodMetrics returns a slice of objects that are meant to be easily
-// consumable by the various termui widgets
-func (m *MetricsClient) GetPodMetrics(o *top.TopPodOptions) ([]MetricValue, error) {
+// consumable by the various termui widgets. Only the metrics source is
+// called, the pod metadata comes from the informer started by Watch.
+func (m *MetricsClient) GetPodMetrics(ctx context.Context, o *top.TopPodOptions) ([]MetricValue, error) {
 	o.MetricsClient = m.m
 	o.PodClient = m.k.CoreV1()
 	o.Namespace = m.ns
@@ -52,102 +54,182 @@ func (m *MetricsClient) GetPodMetrics(o *top.TopPodOptions) ([]MetricValue, erro
 		return nil, errors.New(fmt.Sprintf("invalid sort-by provided: %s", o.SortBy))
 	}
 
-	versionedMetrics := &metricsv1beta1api.PodMetricsList{}
-	mc := o.MetricsClient.MetricsV1beta1()
-	pm := mc.PodMetricses(m.ns)
+	selector, err := podSelector(o)
+	if err != nil {
+		return nil, err
+	}
+	items, err := m.source.PodMetrics(ctx, m.ns, selector)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.setPodMetrics(items)
 
-	var err error
-	selector := labels.Everything()
-	if len(o.LabelSelector) > 0 {
-		selector, err = labels.Parse(o.LabelSelector)
-		if err != nil {
-			return nil, err
-		}
+	values, err := m.podValues(ctx, o, selector, items)
+	if err != nil {
+		return nil, err
 	}
+	if m.stats != nil {
+		m.stats.collect(ctx, values)
+	}
+	if m.throttle != nil {
+		m.throttle.collect(ctx, values)
+	}
+	if m.custom != nil {
+		m.custom.collect(ctx, values)
+	}
+	return values, nil
+}
 
-	// handle getting all or with resource name
-	versionedMetrics, err = pm.List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+// CachedPodMetrics rebuilds the values from the last response of the
+// metrics api and the current state of the pod informer
+func (m *MetricsClient) CachedPodMetrics(ctx context.Context, o *top.TopPodOptions) ([]MetricValue, error) {
+	selector, err := podSelector(o)
 	if err != nil {
 		return nil, err
 	}
-	metrics := &metricsapi.PodMetricsList{}
-	err = metricsv1beta1api.Convert_v1beta1_PodMetricsList_To_metrics_PodMetricsList(versionedMetrics, metrics, nil)
+	values, err := m.podValues(ctx, o, selector, m.cache.getPodMetrics())
 	if err != nil {
 		return nil, err
 	}
+	if m.stats != nil {
+		m.stats.apply(values)
+	}
+	if m.throttle != nil {
+		m.throttle.apply(values)
+	}
+	if m.custom != nil {
+		m.custom.apply(values)
+	}
+	return values, nil
+}
 
-	if len(metrics.Items) == 0 {
-		// If the API server query is successful but all the pods are newly created,
-		// the metrics are probably not ready yet, so we return the error here in the first place.
-		e := verifyEmptyMetrics(*o, selector)
-		if e != nil {
-			return nil, e
-		}
-
-		// if we had no errors, be sure we output something.
-		if o.AllNamespaces {
-			return nil, fmt.Errorf("No resources found\n")
-		} else {
-			return nil, fmt.Errorf("No resources found in %s namespace.\n", o.Namespace)
+// GetPodHistory returns the values of the pods at each step between start
+// and end, oldest first. It is empty when the source doesn't keep history.
+func (m *MetricsClient) GetPodHistory(ctx context.Context, o *top.TopPodOptions, start, end time.Time, step time.Duration) ([][]MetricValue, error) {
+	b, ok := m.source.(Backfiller)
+	if !ok {
+		return nil, nil
+	}
+	selector, err := podSelector(o)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := b.PodHistory(ctx, m.ns, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	history := [][]MetricValue{}
+	for _, items := range steps {
+		values, err := m.podValues(ctx, o, selector, items)
+		if err != nil {
+			return nil, err
 		}
+		history = append(history, values)
 	}
+	return history, nil
+}
 
-	if o.SortBy != "" {
-		sort.Sort(metricsutil.NewPodMetricsSorter(metrics.Items, o.AllNamespaces, o.SortBy))
+func podSelector(o *top.TopPodOptions) (labels.Selector, error) {
+	if len(o.LabelSelector) > 0 {
+		return labels.Parse(o.LabelSelector)
 	}
+	return labels.Everything(), nil
+}
 
-	// maybe loop through containers and sum the cpu/mem limits to calculate percentages
-	podList, err := o.PodClient.Pods(m.ns).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+func (m *MetricsClient) podValues(ctx context.Context, o *top.TopPodOptions, selector labels.Selector, items []metricsapi.PodMetrics) ([]MetricValue, error) {
+	if err := m.cache.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	var pods []*v1.Pod
+	var err error
+	if m.ns == metav1.NamespaceAll {
+		pods, err = m.cache.pods.List(selector)
+	} else {
+		pods, err = m.cache.pods.Pods(m.ns).List(selector)
+	}
 	if err != nil {
 		return nil, err
 	}
-	podMapping := map[string]v1.Pod{}
-	for _, pod := range podList.Items {
-		podMapping[pod.Name] = pod
+	metricsMapping := map[string]metricsapi.PodMetrics{}
+	for _, item := range items {
+		metricsMapping[item.Namespace+"/"+item.Name] = item
 	}
 
+	// every pod is shown even if metrics-server hasn't reported anything
+	// for it yet, those just won't have any usage
 	values := []MetricValue{}
-	for _, item := range metrics.Items {
-		pod := podMapping[item.Name]
-		podMetrics := getPodMetrics(&item)
+	for _, p := range pods {
+		pod := *p
 		limits := getPodResourceLimits(pod)
+		var memUsage resource.Quantity
 		ready, total, restarts := containerStatuses(pod.Status)
-		mem := podMetrics[v1.ResourceMemory]
-		values = append(values, MetricValue{
-			Name:      item.Name,
-			CPUCores:  podMetrics[v1.ResourceCPU],
-			CPULimit:  limits.cpuLimit,
-			MemCores:  mem.Value() / DIVISOR,
-			MemLimit:  limits.memLimit.Value() / DIVISOR,
-			Timestamp: item.Timestamp,
-			Namespace: pod.Namespace,
-			Node:      pod.Spec.NodeName,
-			Status:    string(pod.Status.Phase),
-			Age:       translateTimestampSince(pod.CreationTimestamp),
-			Restarts:  restarts,
-			Ready:     ready,
-			Total:     total,
-		})
+		value := MetricValue{
+			Name:       pod.Name,
+			CPULimit:   limits.cpuLimit,
+			MemLimit:   limits.memLimit.Value() / DIVISOR,
+			CPURequest: limits.cpuRequest,
+			MemRequest: limits.memRequest.Value() / DIVISOR,
+			Namespace:  pod.Namespace,
+			Node:       pod.Spec.NodeName,
+			Status:     podStatus(pod),
+			Age:        translateTimestampSince(pod.CreationTimestamp),
+			Restarts:   restarts,
+			Ready:      ready,
+			Total:      total,
+
+			Workload:           workload(pod),
+			LastTermination:    lastTermination(pod),
+			LimitRangeDefaults: limitRangeDefaults(pod),
+			EphemeralLimit:     limits.ephemeralLimit.Value(),
+		}
+		if item, ok := metricsMapping[pod.Namespace+"/"+pod.Name]; ok {
+			podMetrics := getPodMetrics(&item)
+			memUsage = podMetrics[v1.ResourceMemory]
+			value.CPUCores = podMetrics[v1.ResourceCPU]
+			value.MemCores = memUsage.Value() / DIVISOR
+			value.Timestamp = item.Timestamp
+			value.Window = item.Window.Duration
+		} else if w, ok := m.source.(warmer); ok && w.warmingUp(pod.Namespace+"/"+pod.Name) {
+			value.MetricsReason = "warming up"
+		} else {
+			value.MetricsReason = missingMetricsReason(pod)
+		}
+		// pods that finished don't hold on to anything
+		if m.pricer != nil && pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			value.Cost = m.pricer.cost(m.cache.nodeLabels(pod.Spec.NodeName), limits.cpuRequest, limits.memRequest, value.CPUCores, memUsage)
+		}
+		values = append(values, value)
 	}
 
-	if o.SortBy == "" {
-		// Sort the metrics alphabetically by namespace and name
-		sort.Slice(values, func(i, j int) bool {
-			if values[i].Namespace < values[j].Namespace {
-				return true
-			} else if values[i].Namespace > values[j].Namespace {
-				return false
-			} else {
-				return values[i].Name < values[j].Name
+	// Sort the metrics alphabetically by namespace and name
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Namespace < values[j].Namespace {
+			return true
+		} else if values[i].Namespace > values[j].Namespace {
+			return false
+		} else {
+			return values[i].Name < values[j].Name
+		}
+	})
+	if o.SortBy != "" {
+		// pods without metrics go at the end
+		sort.SliceStable(values, func(i, j int) bool {
+			a, b := values[i], values[j]
+			if a.MetricsReason != "" || b.MetricsReason != "" {
+				return a.MetricsReason == "" && b.MetricsReason != ""
+			}
+			if o.SortBy == "cpu" {
+				return a.CPUCores.Cmp(b.CPUCores) > 0
 			}
+			return a.MemCores > b.MemCores
 		})
 	}
 
 	return values, nil
 }
 
-func (m MetricsClient) GetPod(name, ns string) (string, error) {
-	pod, err := m.k.CoreV1().Pods(ns).Get(context.Background(), name, metav1.GetOptions{})
+func (m MetricsClient) GetPod(ctx context.Context, name, ns string) (string, error) {
+	pod, err := m.k.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
@@ -183,43 +265,19 @@ func translateTimestampSince(timestamp metav1.Time) string {
 	return duration.HumanDuration(time.Since(timestamp.Time))
 }
 
-func verifyEmptyMetrics(o top.TopPodOptions, selector labels.Selector) error {
-	if len(o.ResourceName) > 0 {
-		pod, err := o.PodClient.Pods(o.Namespace).Get(context.TODO(), o.ResourceName, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-		if err := checkPodAge(pod); err != nil {
-			return err
-		}
-	} else {
-		opts := metav1.ListOptions{}
-		if selector != nil {
-			opts.LabelSelector = selector.String()
-		}
-		pods, err := o.PodClient.Pods(o.Namespace).List(context.TODO(), opts)
-		if err != nil {
-			return err
-		}
-		if len(pods.Items) == 0 {
-			return nil
-		}
-		for _, pod := range pods.Items {
-			if err := checkPodAge(&pod); err != nil {
-				return err
-			}
-		}
-	}
-	return errors.New("metrics not available yet")
-}
-
-func checkPodAge(pod *v1.Pod) error {
-	age := time.Since(pod.CreationTimestamp.Time)
-	if age > metricsCreationDelay {
-		message := fmt.Sprintf("Metrics not available for pod %s/%s, age: %s", pod.Namespace, pod.Name, age.String())
-		return errors.New(message)
-	} else {
-		return nil
+// missingMetricsReason explains why the metrics api has no usage for a pod
+func missingMetricsReason(pod v1.Pod) string {
+	switch {
+	case pod.Spec.NodeName == "":
+		return "pod not scheduled"
+	case pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed:
+		return "pod not running"
+	case pod.Status.Phase == v1.PodPending:
+		return "containers not started"
+	case time.Since(pod.CreationTimestamp.Time) <= metricsCreationDelay:
+		return "metrics not available yet"
+	default:
+		return "metrics not reported"
 	}
 }
 
@@ -244,10 +302,12 @@ func getPodResourceLimits(pod v1.Pod) resourceLimits {
 	memLimit, _ := resource.ParseQuantity("0")
 	cpuRequest, _ := resource.ParseQuantity("0")
 	memRequest, _ := resource.ParseQuantity("0")
+	ephemeralLimit, _ := resource.ParseQuantity("0")
 	for _, container := range pod.Spec.Containers {
 		if len(container.Resources.Limits) != 0 {
 			cpuLimit.Add(*container.Resources.Limits.Cpu())
 			memLimit.Add(*container.Resources.Limits.Memory())
+			ephemeralLimit.Add(*container.Resources.Limits.StorageEphemeral())
 		}
 		if len(container.Resources.Requests) != 0 {
 			cpuRequest.Add(*container.Resources.Requests.Cpu())
@@ -259,5 +319,7 @@ func getPodResourceLimits(pod v1.Pod) resourceLimits {
 		memLimit:   memLimit,
 		cpuRequest: cpuRequest,
 		memRequest: memRequest,
+
+		ephemeralLimit: ephemeralLimit,
 	}
 }
@@ -0,0 +1,330 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	metricsapi "k8s.io/metrics/pkg/apis/metrics"
+)
+
+// prometheusSource runs the configured queries against the prometheus http
+// api. The label selector isn't applied in the queries, the results are
+// joined with the pods and nodes from the informers which already are.
+type prometheusSource struct {
+	conf   config.Prometheus
+	window time.Duration
+	http   *http.Client
+}
+
+// promQuery is what the query templates are executed with
+type promQuery struct {
+	Namespace string
+	Window    string
+}
+
+// promResponse is the body of the query and query_range endpoints
+type promResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+			Values [][]interface{}   `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// promPoint is a single value of a series returned by prometheus
+type promPoint struct {
+	labels map[string]string
+	at     time.Time
+	value  float64
+}
+
+// ValidatePrometheus checks the prometheus source from the config file
+func ValidatePrometheus(p config.Prometheus) error {
+	window, err := time.ParseDuration(p.Window)
+	if err != nil {
+		return fmt.Errorf("invalid prometheus window %q: %w", p.Window, err)
+	}
+	if window <= 0 {
+		return fmt.Errorf("invalid prometheus window %q, must be positive", p.Window)
+	}
+	return nil
+}
+
+// newPrometheusSource expects the config to have been checked by
+// ValidatePrometheus
+func newPrometheusSource(conf config.Prometheus) *prometheusSource {
+	window, _ := time.ParseDuration(conf.Window)
+	return &prometheusSource{conf: conf, window: window, http: http.DefaultClient}
+}
+
+func (s *prometheusSource) PodMetrics(ctx context.Context, namespace string, _ labels.Selector) ([]metricsapi.PodMetrics, error) {
+	cpu, mem, err := s.instant(ctx, s.conf.PodCPU, s.conf.PodMemory, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return s.podMetrics(cpu, mem), nil
+}
+
+func (s *prometheusSource) NodeMetrics(ctx context.Context, _ labels.Selector) ([]metricsapi.NodeMetrics, error) {
+	cpu, mem, err := s.instant(ctx, s.conf.NodeCPU, s.conf.NodeMemory, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.nodeMetrics(cpu, mem), nil
+}
+
+func (s *prometheusSource) PodHistory(ctx context.Context, namespace string, start, end time.Time, step time.Duration) ([][]metricsapi.PodMetrics, error) {
+	cpu, mem, err := s.ranged(ctx, s.conf.PodCPU, s.conf.PodMemory, namespace, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	history := [][]metricsapi.PodMetrics{}
+	for _, at := range stepTimes(cpu) {
+		history = append(history, s.podMetrics(cpu[at], mem[at]))
+	}
+	return history, nil
+}
+
+func (s *prometheusSource) NodeHistory(ctx context.Context, start, end time.Time, step time.Duration) ([][]metricsapi.NodeMetrics, error) {
+	cpu, mem, err := s.ranged(ctx, s.conf.NodeCPU, s.conf.NodeMemory, "", start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	history := [][]metricsapi.NodeMetrics{}
+	for _, at := range stepTimes(cpu) {
+		history = append(history, s.nodeMetrics(cpu[at], mem[at]))
+	}
+	return history, nil
+}
+
+func (s *prometheusSource) podMetrics(cpu, mem []promPoint) []metricsapi.PodMetrics {
+	memory := map[string]float64{}
+	for _, p := range mem {
+		memory[p.labels["namespace"]+"/"+p.labels["pod"]] = p.value
+	}
+	items := []metricsapi.PodMetrics{}
+	for _, p := range cpu {
+		ns, name := p.labels["namespace"], p.labels["pod"]
+		if name == "" {
+			continue
+		}
+		items = append(items, metricsapi.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Timestamp:  metav1.NewTime(p.at),
+			Window:     metav1.Duration{Duration: s.window},
+			Containers: []metricsapi.ContainerMetrics{{
+				Usage: usage(p.value, memory[ns+"/"+name]),
+			}},
+		})
+	}
+	return items
+}
+
+func (s *prometheusSource) nodeMetrics(cpu, mem []promPoint) []metricsapi.NodeMetrics {
+	memory := map[string]float64{}
+	for _, p := range mem {
+		memory[p.labels["node"]] = p.value
+	}
+	items := []metricsapi.NodeMetrics{}
+	for _, p := range cpu {
+		name := p.labels["node"]
+		if name == "" {
+			continue
+		}
+		items = append(items, metricsapi.NodeMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Timestamp:  metav1.NewTime(p.at),
+			Window:     metav1.Duration{Duration: s.window},
+			Usage:      usage(p.value, memory[name]),
+		})
+	}
+	return items
+}
+
+func usage(cores, bytes float64) v1.ResourceList {
+	return v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewMilliQuantity(int64(cores*1000), resource.DecimalSI),
+		v1.ResourceMemory: *resource.NewQuantity(int64(bytes), resource.BinarySI),
+	}
+}
+
+// instant runs the cpu and memory queries at the current time
+func (s *prometheusSource) instant(ctx context.Context, cpuQuery, memQuery, namespace string) ([]promPoint, []promPoint, error) {
+	var points [2][]promPoint
+	for i, query := range []string{cpuQuery, memQuery} {
+		q, err := s.render(query, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := s.get(ctx, "/api/v1/query", url.Values{"query": {q}})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range resp.Data.Result {
+			p, err := promValue(r.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !p.finite() {
+				continue
+			}
+			p.labels = r.Metric
+			points[i] = append(points[i], p)
+		}
+	}
+	return points[0], points[1], nil
+}
+
+// ranged runs the cpu and memory queries over a range and groups the points
+// by the step they were evaluated at
+func (s *prometheusSource) ranged(ctx context.Context, cpuQuery, memQuery, namespace string, start, end time.Time, step time.Duration) (map[time.Time][]promPoint, map[time.Time][]promPoint, error) {
+	var points [2]map[time.Time][]promPoint
+	for i, query := range []string{cpuQuery, memQuery} {
+		q, err := s.render(query, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := s.get(ctx, "/api/v1/query_range", url.Values{
+			"query": {q},
+			"start": {promTime(start)},
+			"end":   {promTime(end)},
+			"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		points[i] = map[time.Time][]promPoint{}
+		for _, r := range resp.Data.Result {
+			for _, v := range r.Values {
+				p, err := promValue(v)
+				if err != nil {
+					return nil, nil, err
+				}
+				if !p.finite() {
+					continue
+				}
+				p.labels = r.Metric
+				points[i][p.at] = append(points[i][p.at], p)
+			}
+		}
+	}
+	return points[0], points[1], nil
+}
+
+func (s *prometheusSource) render(query, namespace string) (string, error) {
+	t, err := template.New("query").Parse(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid prometheus query template: %w", err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, promQuery{Namespace: namespace, Window: s.conf.Window}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (s *prometheusSource) get(ctx context.Context, path string, params url.Values) (promResponse, error) {
+	var resp promResponse
+	if s.conf.URL == "" {
+		return resp, errors.New("no prometheus url configured, set --prometheus-url or prometheus.url in the config file")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.conf.URL, "/")+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return resp, err
+	}
+	res, err := s.http.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return resp, err
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return resp, fmt.Errorf("prometheus returned %s: %w", res.Status, err)
+	}
+	if resp.Status != "success" {
+		return resp, fmt.Errorf("prometheus query failed: %s", resp.Error)
+	}
+	return resp, nil
+}
+
+// finite is false for NaN and infinite values, ie from a division by zero,
+// they are dropped rather than shown as any usage
+func (p promPoint) finite() bool {
+	return !math.IsNaN(p.value) && !math.IsInf(p.value, 0)
+}
+
+// promValue parses a [<unix time>, "<value>"] pair
+func promValue(v []interface{}) (promPoint, error) {
+	var p promPoint
+	if len(v) != 2 {
+		return p, fmt.Errorf("invalid prometheus value %v", v)
+	}
+	ts, ok := v[0].(float64)
+	if !ok {
+		return p, fmt.Errorf("invalid prometheus timestamp %v", v[0])
+	}
+	str, ok := v[1].(string)
+	if !ok {
+		return p, fmt.Errorf("invalid prometheus value %v", v[1])
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return p, err
+	}
+	sec, frac := math.Modf(ts)
+	p.at = time.Unix(int64(sec), int64(frac*1e9))
+	p.value = value
+	return p, nil
+}
+
+func promTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+func stepTimes(points map[time.Time][]promPoint) []time.Time {
+	times := make([]time.Time, 0, len(points))
+	for t := range points {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chriskim06/kubectl-topui/internal/config"
+	v1 "k8s.io/api/core/v1"
+)
+
+var testPrometheus = config.Prometheus{
+	Window:     "2m",
+	PodCPU:     `cpu{{if .Namespace}}{namespace="{{.Namespace}}"}{{end}}[{{.Window}}]`,
+	PodMemory:  `mem{{if .Namespace}}{namespace="{{.Namespace}}"}{{end}}`,
+	NodeCPU:    `node_cpu[{{.Window}}]`,
+	NodeMemory: `node_mem`,
+}
+
+// prometheusServer answers each query with the body registered for it and
+// records the queries it was asked
+func prometheusServer(t *testing.T, bodies map[string]string) (*prometheusSource, *[]url.Values) {
+	t.Helper()
+	requests := []url.Values{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" && r.URL.Path != "/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		params := r.URL.Query()
+		params.Set("path", r.URL.Path)
+		requests = append(requests, params)
+		body, ok := bodies[params.Get("query")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unexpected query"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	conf := testPrometheus
+	conf.URL = srv.URL + "/"
+	return newPrometheusSource(conf), &requests
+}
+
+func TestPrometheusRender(t *testing.T) {
+	s := newPrometheusSource(testPrometheus)
+	tests := []struct {
+		query     string
+		namespace string
+		want      string
+	}{
+		{testPrometheus.PodCPU, "default", `cpu{namespace="default"}[2m]`},
+		{testPrometheus.PodCPU, "", `cpu[2m]`},
+		{testPrometheus.NodeMemory, "", `node_mem`},
+	}
+	for _, tt := range tests {
+		got, err := s.render(tt.query, tt.namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("render(%q, %q) = %q, want %q", tt.query, tt.namespace, got, tt.want)
+		}
+	}
+	if _, err := s.render("{{.Missing", ""); err == nil || !strings.Contains(err.Error(), "invalid prometheus query template") {
+		t.Errorf("expected a template error, got %v", err)
+	}
+}
+
+func TestPrometheusPodHistory(t *testing.T) {
+	s, requests := prometheusServer(t, map[string]string{
+		`cpu{namespace="default"}[2m]`: `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web"},"values":[[1700000000,"0.25"],[1700000030,"0.5"]]},
+			{"metric":{"namespace":"default","pod":"db"},"values":[[1700000030,"NaN"]]}
+		]}}`,
+		`mem{namespace="default"}`: `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web"},"values":[[1700000000,"1048576"],[1700000030,"2097152"]]}
+		]}}`,
+	})
+	start := time.Unix(1700000000, 0)
+	history, err := s.PodHistory(context.Background(), "default", start, start.Add(30*time.Second), 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(*requests))
+	}
+	req := (*requests)[0]
+	if req.Get("path") != "/api/v1/query_range" || req.Get("start") != "1700000000.000" || req.Get("end") != "1700000030.000" || req.Get("step") != "30" {
+		t.Errorf("unexpected range request %v", req)
+	}
+
+	if len(history) != 2 {
+		t.Fatalf("got %d steps, want 2", len(history))
+	}
+	// the NaN of db from an empty rate is dropped rather than shown as no
+	// usage
+	if len(history[0]) != 1 || len(history[1]) != 1 {
+		t.Fatalf("got %d and %d pods in the steps, want 1 and 1", len(history[0]), len(history[1]))
+	}
+	for _, p := range history[1] {
+		cpu := p.Containers[0].Usage[v1.ResourceCPU]
+		mem := p.Containers[0].Usage[v1.ResourceMemory]
+		switch p.Name {
+		case "web":
+			if cpu.MilliValue() != 500 || mem.Value() != 2097152 {
+				t.Errorf("web = %dm %d bytes, want 500m 2097152 bytes", cpu.MilliValue(), mem.Value())
+			}
+		default:
+			t.Errorf("unexpected pod %s", p.Name)
+		}
+		if !p.Timestamp.Time.Equal(start.Add(30*time.Second)) || p.Window.Duration != 2*time.Minute {
+			t.Errorf("%s was taken at %s over %s", p.Name, p.Timestamp.Time, p.Window.Duration)
+		}
+	}
+}
+
+func TestPrometheusNodeMetrics(t *testing.T) {
+	s, requests := prometheusServer(t, map[string]string{
+		`node_cpu[2m]`: `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"node":"node-1"},"value":[1700000000.5,"1.5"]},
+			{"metric":{},"value":[1700000000.5,"3"]}
+		]}}`,
+		`node_mem`: `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"node":"node-1"},"value":[1700000000.5,"4294967296"]}
+		]}}`,
+	})
+	items, err := s.NodeMetrics(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*requests)[0].Get("path") != "/api/v1/query" {
+		t.Errorf("expected an instant query, got %s", (*requests)[0].Get("path"))
+	}
+	// the series without a node label is skipped
+	if len(items) != 1 || items[0].Name != "node-1" {
+		t.Fatalf("unexpected nodes %+v", items)
+	}
+	cpu, mem := items[0].Usage[v1.ResourceCPU], items[0].Usage[v1.ResourceMemory]
+	if cpu.MilliValue() != 1500 || mem.Value() != 4294967296 {
+		t.Errorf("node-1 = %dm %d bytes, want 1500m 4294967296 bytes", cpu.MilliValue(), mem.Value())
+	}
+	if want := time.Unix(1700000000, 5e8); !items[0].Timestamp.Time.Equal(want) {
+		t.Errorf("node-1 was taken at %s, want %s", items[0].Timestamp.Time, want)
+	}
+}
+
+func TestPrometheusErrors(t *testing.T) {
+	s, _ := prometheusServer(t, map[string]string{
+		`node_cpu[2m]`: `<html>bad gateway</html>`,
+		`cpu[2m]`:      `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"pod":"web"},"value":[1700000000,5]}]}}`,
+	})
+	ctx := context.Background()
+
+	if _, err := s.NodeMetrics(ctx, nil); err == nil || !strings.Contains(err.Error(), "prometheus returned 200 OK") {
+		t.Errorf("expected an error for a body that isn't json, got %v", err)
+	}
+	if _, err := s.PodMetrics(ctx, "", nil); err == nil || !strings.Contains(err.Error(), "invalid prometheus value") {
+		t.Errorf("expected an error for a value that isn't a string, got %v", err)
+	}
+	if _, err := s.PodMetrics(ctx, "default", nil); err == nil || !strings.Contains(err.Error(), "unexpected query") {
+		t.Errorf("expected the error from prometheus, got %v", err)
+	}
+
+	s = newPrometheusSource(testPrometheus)
+	if _, err := s.PodMetrics(ctx, "", nil); err == nil || !strings.Contains(err.Error(), "no prometheus url configured") {
+		t.Errorf("expected an error without a url, got %v", err)
+	}
+}
+
+func TestValidatePrometheus(t *testing.T) {
+	tests := []struct {
+		window string
+		valid  bool
+	}{
+		{window: "1m", valid: true},
+		{window: "90s", valid: true},
+		{window: "", valid: false},
+		{window: "5", valid: false},
+		{window: "0s", valid: false},
+		{window: "-1m", valid: false},
+	}
+	for _, tt := range tests {
+		conf := testPrometheus
+		conf.Window = tt.window
+		if err := ValidatePrometheus(conf); (err == nil) != tt.valid {
+			t.Errorf("window %q: expected valid to be %v, got %v", tt.window, tt.valid, err)
+		}
+	}
+}
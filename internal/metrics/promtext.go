@@ -0,0 +1,128 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promSample is a single line of the prometheus text exposition format
+type promSample struct {
+	name      string
+	labels    map[string]string
+	value     float64
+	timestamp time.Time
+}
+
+// parsePromText parses the samples in the prometheus text format. Comments
+// and type information are skipped, only the samples are needed.
+func parsePromText(data []byte) ([]promSample, error) {
+	samples := []promSample{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		s, err := parsePromLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, scanner.Err()
+}
+
+func parsePromLine(text string) (promSample, error) {
+	s := promSample{labels: map[string]string{}}
+	end := strings.IndexAny(text, "{ \t")
+	if end < 0 {
+		return s, fmt.Errorf("missing value: %s", text)
+	}
+	s.name, text = text[:end], text[end:]
+	if strings.HasPrefix(text, "{") {
+		rest, err := parsePromLabels(text[1:], s.labels)
+		if err != nil {
+			return s, err
+		}
+		text = rest
+	}
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, fmt.Errorf("invalid sample for %s", s.name)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, err
+	}
+	s.value = value
+	if len(fields) == 2 {
+		ms, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return s, err
+		}
+		s.timestamp = time.UnixMilli(ms)
+	}
+	return s, nil
+}
+
+// parsePromLabels reads label pairs up to the closing brace and returns
+// what is left of the line
+func parsePromLabels(text string, labels map[string]string) (string, error) {
+	for {
+		text = strings.TrimLeft(text, " ,")
+		if strings.HasPrefix(text, "}") {
+			return text[1:], nil
+		}
+		eq := strings.Index(text, "=")
+		if eq < 0 || len(text) < eq+2 || text[eq+1] != '"' {
+			return "", fmt.Errorf("invalid label in %s", text)
+		}
+		name := strings.TrimSpace(text[:eq])
+		text = text[eq+2:]
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			if c == '\\' && i+1 < len(text) {
+				i++
+				switch text[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(text[i])
+				}
+				continue
+			}
+			if c == '"' {
+				text = text[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return "", fmt.Errorf("unterminated value for label %s", name)
+		}
+		labels[name] = value.String()
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePromText(t *testing.T) {
+	data := []byte(`# HELP container_cpu_usage_seconds_total [STABLE] Cumulative cpu time consumed by the container in core-seconds
+# TYPE container_cpu_usage_seconds_total counter
+container_cpu_usage_seconds_total{container="app",namespace="default",pod="web-0"} 12.5 1700000000000
+
+node_memory_working_set_bytes 2.097152e+09
+scrape_error{path="a\"b\\c",msg="line\nbreak",} 0
+`)
+	samples, err := parsePromText(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []promSample{
+		{
+			name:      "container_cpu_usage_seconds_total",
+			labels:    map[string]string{"container": "app", "namespace": "default", "pod": "web-0"},
+			value:     12.5,
+			timestamp: time.UnixMilli(1700000000000),
+		},
+		{
+			name:   "node_memory_working_set_bytes",
+			labels: map[string]string{},
+			value:  2097152000,
+		},
+		{
+			name:   "scrape_error",
+			labels: map[string]string{"path": `a"b\c`, "msg": "line\nbreak"},
+		},
+	}
+	if !reflect.DeepEqual(samples, want) {
+		t.Errorf("got %+v, want %+v", samples, want)
+	}
+}
+
+func TestParsePromTextErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing value":      "node_cpu_usage_seconds_total",
+		"invalid value":      "node_cpu_usage_seconds_total abc",
+		"invalid timestamp":  "node_cpu_usage_seconds_total 1 abc",
+		"too many fields":    "node_cpu_usage_seconds_total 1 2 3",
+		"unterminated label": `container_cpu_usage_seconds_total{pod="web} 1`,
+		"unquoted label":     `container_cpu_usage_seconds_total{pod=web} 1`,
+	}
+	for name, line := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parsePromText([]byte(line)); err == nil {
+				t.Errorf("expected an error for %q", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// PVCOptions are the options of the pvc view
+type PVCOptions struct {
+	LabelSelector string
+	AllNamespaces bool
+}
+
+// mounts are the pods that mount each claim by its key
+type mounts map[string][]*v1.Pod
+
+// GetPVCMetrics returns the persistent volume claims with the volume stats
+// that the kubelets of the pods mounting them report in the summary api.
+// The metrics source isn't used, volume stats are only in the summary.
+func (m MetricsClient) GetPVCMetrics(ctx context.Context, o *PVCOptions) ([]MetricValue, error) {
+	selector, err := labels.Parse(o.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.cache.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	claims, err := m.listPVCs(selector)
+	if err != nil {
+		return nil, err
+	}
+	mounted, err := m.mounts()
+	if err != nil {
+		return nil, err
+	}
+
+	// only the nodes running pods that mount the listed claims are read
+	nodes := map[string]bool{}
+	for _, pvc := range claims {
+		for _, pod := range mounted[pvc.Namespace+"/"+pvc.Name] {
+			if pod.Spec.NodeName != "" && pod.Status.Phase == v1.PodRunning {
+				nodes[pod.Spec.NodeName] = true
+			}
+		}
+	}
+	var mu sync.Mutex
+	volumes := map[string]volumeStats{}
+	_, err = eachNode(ctx, nodes, func(ctx context.Context, name string) error {
+		s, err := getSummary(ctx, m.k, name)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, pod := range s.Pods {
+			for _, v := range pod.Volumes {
+				if v.PVCRef == nil {
+					continue
+				}
+				// a claim mounted on several nodes reports the same
+				// volume, the most recent reading wins
+				key := v.PVCRef.Namespace + "/" + v.PVCRef.Name
+				if prev, ok := volumes[key]; !ok || v.Time.After(prev.Time) {
+					volumes[key] = v
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.cache.setVolumes(volumes)
+	return pvcValues(claims, mounted, volumes), nil
+}
+
+// CachedPVCMetrics rebuilds the values from the last volume stats and the
+// current state of the informers
+func (m MetricsClient) CachedPVCMetrics(ctx context.Context, o *PVCOptions) ([]MetricValue, error) {
+	selector, err := labels.Parse(o.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.cache.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	claims, err := m.listPVCs(selector)
+	if err != nil {
+		return nil, err
+	}
+	mounted, err := m.mounts()
+	if err != nil {
+		return nil, err
+	}
+	return pvcValues(claims, mounted, m.cache.getVolumes()), nil
+}
+
+func (m MetricsClient) listPVCs(selector labels.Selector) ([]*v1.PersistentVolumeClaim, error) {
+	if m.ns == metav1.NamespaceAll {
+		return m.cache.pvcs.List(selector)
+	}
+	return m.cache.pvcs.PersistentVolumeClaims(m.ns).List(selector)
+}
+
+// mounts finds the pods that use each claim
+func (m MetricsClient) mounts() (mounts, error) {
+	var pods []*v1.Pod
+	var err error
+	if m.ns == metav1.NamespaceAll {
+		pods, err = m.cache.pods.List(labels.Everything())
+	} else {
+		pods, err = m.cache.pods.Pods(m.ns).List(labels.Everything())
+	}
+	if err != nil {
+		return nil, err
+	}
+	mounted := mounts{}
+	for _, pod := range pods {
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim == nil {
+				continue
+			}
+			key := pod.Namespace + "/" + v.PersistentVolumeClaim.ClaimName
+			mounted[key] = append(mounted[key], pod)
+		}
+	}
+	return mounted, nil
+}
+
+func pvcValues(claims []*v1.PersistentVolumeClaim, mounted mounts, volumes map[string]volumeStats) []MetricValue {
+	values := []MetricValue{}
+	for _, pvc := range claims {
+		key := pvc.Namespace + "/" + pvc.Name
+		volume := &Volume{}
+		if pvc.Spec.StorageClassName != nil {
+			volume.StorageClass = *pvc.Spec.StorageClassName
+		}
+		if capacity, ok := pvc.Status.Capacity[v1.ResourceStorage]; ok {
+			volume.Capacity = capacity.Value()
+		}
+		for _, pod := range mounted[key] {
+			volume.Pods = append(volume.Pods, pod.Name)
+		}
+		sort.Strings(volume.Pods)
+		v := MetricValue{
+			Name:      pvc.Name,
+			Namespace: pvc.Namespace,
+			Status:    string(pvc.Status.Phase),
+			Age:       translateTimestampSince(pvc.CreationTimestamp),
+			Volume:    volume,
+		}
+		if stats, ok := volumes[key]; ok {
+			// the kubelet knows the size of the filesystem which can differ
+			// from what was requested
+			if stats.CapacityBytes != nil {
+				volume.Capacity = value(stats.CapacityBytes)
+			}
+			volume.Used = value(stats.UsedBytes)
+			volume.Available = value(stats.AvailableBytes)
+			volume.Inodes = value(stats.Inodes)
+			volume.InodesUsed = value(stats.InodesUsed)
+			v.Timestamp = metav1.NewTime(stats.Time)
+		} else if len(volume.Pods) == 0 {
+			v.MetricsReason = "not mounted"
+		} else {
+			v.MetricsReason = "volume stats not reported"
+		}
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Key() < values[j].Key()
+	})
+	return values
+}
+
+func (m MetricsClient) GetPVC(ctx context.Context, name, ns string) (string, error) {
+	pvc, err := m.k.CoreV1().PersistentVolumeClaims(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if !m.showManagedFields {
+		pvc.ManagedFields = nil
+	}
+	s, err := yaml.Marshal(pvc)
+	if err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// the annotation the LimitRanger admission plugin sets on the pods it
+// filled in requests or limits for
+const limitRangerAnnotation = "kubernetes.io/limit-ranger"
+
+// QuotaReport is the resource quota usage of a namespace along with the
+// limit range defaults that were applied to its pods
+type QuotaReport struct {
+	Namespace   string
+	Quotas      []Quota
+	LimitRanges []LimitRangeDefaults
+	Defaulted   []DefaultedPod
+}
+
+// Quota is the hard limit and usage of every resource in a ResourceQuota
+type Quota struct {
+	Name      string
+	Scopes    []string
+	Resources []QuotaResource
+}
+
+// QuotaResource is a single resource of a quota. Fraction is used over
+// hard and is 1 or more when the quota is exhausted.
+type QuotaResource struct {
+	Name     string
+	Used     string
+	Hard     string
+	Fraction float64
+}
+
+// LimitRangeDefaults are the defaults a LimitRange fills in for containers
+// that don't set their own
+type LimitRangeDefaults struct {
+	Name           string
+	Default        string
+	DefaultRequest string
+}
+
+// DefaultedPod is a pod that had requests or limits set by a LimitRange
+type DefaultedPod struct {
+	Name    string
+	Applied string
+}
+
+// GetQuotaReport returns the quotas and limit range defaults of a namespace
+func (m MetricsClient) GetQuotaReport(ctx context.Context, ns string) (QuotaReport, error) {
+	report := QuotaReport{Namespace: ns}
+	quotas, err := m.k.CoreV1().ResourceQuotas(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return report, err
+	}
+	for _, q := range quotas.Items {
+		quota := Quota{Name: q.Name}
+		for _, scope := range q.Spec.Scopes {
+			quota.Scopes = append(quota.Scopes, string(scope))
+		}
+		for name, hard := range q.Status.Hard {
+			used := q.Status.Used[name]
+			r := QuotaResource{Name: string(name), Used: used.String(), Hard: hard.String()}
+			if hard.IsZero() {
+				// nothing is allowed so any usage is over
+				if !used.IsZero() {
+					r.Fraction = 1
+				}
+			} else {
+				r.Fraction = used.AsApproximateFloat64() / hard.AsApproximateFloat64()
+			}
+			quota.Resources = append(quota.Resources, r)
+		}
+		sort.Slice(quota.Resources, func(i, j int) bool {
+			return quota.Resources[i].Name < quota.Resources[j].Name
+		})
+		report.Quotas = append(report.Quotas, quota)
+	}
+
+	limitRanges, err := m.k.CoreV1().LimitRanges(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return report, err
+	}
+	for _, lr := range limitRanges.Items {
+		for _, limit := range lr.Spec.Limits {
+			if limit.Type != v1.LimitTypeContainer || (len(limit.Default) == 0 && len(limit.DefaultRequest) == 0) {
+				continue
+			}
+			report.LimitRanges = append(report.LimitRanges, LimitRangeDefaults{
+				Name:           lr.Name,
+				Default:        resourceList(limit.Default),
+				DefaultRequest: resourceList(limit.DefaultRequest),
+			})
+		}
+	}
+
+	// the pods are only listed when the informer doesn't already have them
+	pods, cached, err := m.namespacePods(ctx, ns)
+	if err != nil {
+		return report, err
+	}
+	if cached {
+		for _, pod := range pods {
+			if applied := limitRangeDefaults(*pod); applied != "" {
+				report.Defaulted = append(report.Defaulted, DefaultedPod{Name: pod.Name, Applied: applied})
+			}
+		}
+		sort.Slice(report.Defaulted, func(i, j int) bool {
+			return report.Defaulted[i].Name < report.Defaulted[j].Name
+		})
+		return report, nil
+	}
+	list := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return m.k.CoreV1().Pods(ns).List(ctx, opts)
+	}
+	err = m.eachListItem(ctx, metav1.ListOptions{}, list, func(obj runtime.Object) error {
+		pod := obj.(*v1.Pod)
+		if applied := limitRangeDefaults(*pod); applied != "" {
+			report.Defaulted = append(report.Defaulted, DefaultedPod{Name: pod.Name, Applied: applied})
+		}
+		return nil
+	})
+	return report, err
+}
+
+// limitRangeDefaults returns what the LimitRanger admission plugin set on
+// the pod, ie "cpu, memory limit for container app"
+func limitRangeDefaults(pod v1.Pod) string {
+	applied, ok := pod.Annotations[limitRangerAnnotation]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(applied, "LimitRanger plugin set:"))
+}
+
+func resourceList(l v1.ResourceList) string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		q := l[v1.ResourceName(name)]
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, q.String()))
+	}
+	return strings.Join(pairs, ", ")
+}
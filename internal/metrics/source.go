@@ -0,0 +1,124 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	metricsapi "k8s.io/metrics/pkg/apis/metrics"
+	metricsv1beta1api "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+const (
+	// MetricsServerSource reads the metrics.k8s.io api
+	MetricsServerSource = "metrics-server"
+
+	// KubeletSource reads the /metrics/resource endpoint of every kubelet
+	// through the api server node proxy
+	KubeletSource = "kubelet"
+
+	// PrometheusSource queries the prometheus http api
+	PrometheusSource = "prometheus"
+)
+
+// Sources are the names of the available metrics backends
+var Sources = []string{MetricsServerSource, KubeletSource, PrometheusSource}
+
+// Source is where the usage of pods and nodes comes from. The values are
+// joined with the pod and node metadata from the informers afterwards.
+type Source interface {
+	PodMetrics(ctx context.Context, namespace string, selector labels.Selector) ([]metricsapi.PodMetrics, error)
+	NodeMetrics(ctx context.Context, selector labels.Selector) ([]metricsapi.NodeMetrics, error)
+}
+
+// Backfiller is implemented by the sources that keep history so the graphs
+// can start out with it. Each returned slice is the metrics at one step.
+type Backfiller interface {
+	PodHistory(ctx context.Context, namespace string, start, end time.Time, step time.Duration) ([][]metricsapi.PodMetrics, error)
+	NodeHistory(ctx context.Context, start, end time.Time, step time.Duration) ([][]metricsapi.NodeMetrics, error)
+}
+
+// warmer is implemented by the sources that need more than one reading
+// before they have usage. The key is namespace/name for pods and the name
+// for nodes.
+type warmer interface {
+	warmingUp(key string) bool
+}
+
+// ValidateSource checks that the name is one of the available sources
+func ValidateSource(name string) error {
+	for _, s := range Sources {
+		if s == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown source %q, must be one of: %s", name, strings.Join(Sources, ", "))
+}
+
+func newSource(opts Options, m MetricsClient) Source {
+	switch opts.Source {
+	case KubeletSource:
+		return newKubeletSource(m)
+	case PrometheusSource:
+		return newPrometheusSource(opts.Prometheus)
+	}
+	return metricsServer{m}
+}
+
+// metricsServer lists the metrics api a chunk at a time
+type metricsServer struct {
+	client MetricsClient
+}
+
+func (s metricsServer) PodMetrics(ctx context.Context, namespace string, selector labels.Selector) ([]metricsapi.PodMetrics, error) {
+	pm := s.client.m.MetricsV1beta1().PodMetricses(namespace)
+	items := []metricsapi.PodMetrics{}
+	list := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return pm.List(ctx, opts)
+	}
+	err := s.client.eachListItem(ctx, metav1.ListOptions{LabelSelector: selector.String()}, list, func(obj runtime.Object) error {
+		var item metricsapi.PodMetrics
+		if err := metricsv1beta1api.Convert_v1beta1_PodMetrics_To_metrics_PodMetrics(obj.(*metricsv1beta1api.PodMetrics), &item, nil); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+func (s metricsServer) NodeMetrics(ctx context.Context, selector labels.Selector) ([]metricsapi.NodeMetrics, error) {
+	nm := s.client.m.MetricsV1beta1().NodeMetricses()
+	items := []metricsapi.NodeMetrics{}
+	list := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return nm.List(ctx, opts)
+	}
+	err := s.client.eachListItem(ctx, metav1.ListOptions{LabelSelector: selector.String()}, list, func(obj runtime.Object) error {
+		var item metricsapi.NodeMetrics
+		if err := metricsv1beta1api.Convert_v1beta1_NodeMetrics_To_metrics_NodeMetrics(obj.(*metricsv1beta1api.NodeMetrics), &item, nil); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// the reason the node lifecycle controller sets on pods of unreachable nodes
+const nodeUnreachablePodReason = "NodeLost"
+
+// podStatus returns the same status reason that kubectl get pods prints
+// in its STATUS column
+func podStatus(pod v1.Pod) string {
+	reason := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Reason == v1.PodReasonSchedulingGated {
+			reason = v1.PodReasonSchedulingGated
+		}
+	}
+
+	// restartable init containers (sidecars) keep running after the pod is
+	// initialized. The api version vendored here has no restartPolicy on
+	// containers so they are told apart by having started in a pod that
+	// is initialized.
+	initialized := hasPodCondition(pod.Status.Conditions, v1.PodInitialized)
+	initializing := false
+	for i, container := range pod.Status.InitContainerStatuses {
+		switch {
+		case container.State.Terminated != nil && container.State.Terminated.ExitCode == 0:
+			continue
+		case initialized && container.State.Terminated == nil && container.Started != nil && *container.Started:
+			continue
+		case container.State.Terminated != nil:
+			// initialization failed
+			if len(container.State.Terminated.Reason) == 0 {
+				if container.State.Terminated.Signal != 0 {
+					reason = fmt.Sprintf("Init:Signal:%d", container.State.Terminated.Signal)
+				} else {
+					reason = fmt.Sprintf("Init:ExitCode:%d", container.State.Terminated.ExitCode)
+				}
+			} else {
+				reason = "Init:" + container.State.Terminated.Reason
+			}
+			initializing = true
+		case container.State.Waiting != nil && len(container.State.Waiting.Reason) > 0 && container.State.Waiting.Reason != "PodInitializing":
+			reason = "Init:" + container.State.Waiting.Reason
+			initializing = true
+		default:
+			reason = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+			initializing = true
+		}
+		break
+	}
+
+	if !initializing || initialized {
+		hasRunning := false
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			container := pod.Status.ContainerStatuses[i]
+			if container.State.Waiting != nil && container.State.Waiting.Reason != "" {
+				reason = container.State.Waiting.Reason
+			} else if container.State.Terminated != nil && container.State.Terminated.Reason != "" {
+				reason = container.State.Terminated.Reason
+			} else if container.State.Terminated != nil && container.State.Terminated.Reason == "" {
+				if container.State.Terminated.Signal != 0 {
+					reason = fmt.Sprintf("Signal:%d", container.State.Terminated.Signal)
+				} else {
+					reason = fmt.Sprintf("ExitCode:%d", container.State.Terminated.ExitCode)
+				}
+			} else if container.Ready && container.State.Running != nil {
+				hasRunning = true
+			}
+		}
+
+		// change pod status back to "Running" if there is at least one container still reporting as "Running" status
+		if reason == "Completed" && hasRunning {
+			if hasPodCondition(pod.Status.Conditions, v1.PodReady) {
+				reason = "Running"
+			} else {
+				reason = "NotReady"
+			}
+		}
+	}
+
+	if pod.DeletionTimestamp != nil && pod.Status.Reason == nodeUnreachablePodReason {
+		reason = "Unknown"
+	} else if pod.DeletionTimestamp != nil {
+		reason = "Terminating"
+	}
+
+	return reason
+}
+
+func hasPodCondition(conditions []v1.PodCondition, conditionType v1.PodConditionType) bool {
+	for _, condition := range conditions {
+		if condition.Type == conditionType && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// lastTermination returns the reason and time of the most recent container
+// termination in the pod, ie "OOMKilled at 10:42". A container that failed
+// and wasn't restarted is counted by its current state since it never gets
+// a last termination, the ones that completed are left out.
+func lastTermination(pod v1.Pod) string {
+	var last *v1.ContainerStateTerminated
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for i := range statuses {
+		terminated := statuses[i].State.Terminated
+		if terminated == nil || terminated.ExitCode == 0 {
+			terminated = statuses[i].LastTerminationState.Terminated
+		}
+		if terminated == nil {
+			continue
+		}
+		if last == nil || terminated.FinishedAt.After(last.FinishedAt.Time) {
+			last = terminated
+		}
+	}
+	if last == nil {
+		return ""
+	}
+
+	reason := last.Reason
+	if reason == "" {
+		if last.Signal != 0 {
+			reason = fmt.Sprintf("Signal:%d", last.Signal)
+		} else {
+			reason = fmt.Sprintf("ExitCode:%d", last.ExitCode)
+		}
+	}
+	if last.FinishedAt.IsZero() {
+		return reason
+	}
+	finished := last.FinishedAt.Local()
+	layout := "15:04"
+	if y, m, d := time.Now().Date(); finished.Year() != y || finished.Month() != m || finished.Day() != d {
+		layout = "Jan 2 15:04"
+	}
+	return fmt.Sprintf("%s at %s", reason, finished.Format(layout))
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func running(name string, ready bool) v1.ContainerStatus {
+	started := true
+	return v1.ContainerStatus{Name: name, Ready: ready, Started: &started, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}
+}
+
+func waiting(name, reason string) v1.ContainerStatus {
+	return v1.ContainerStatus{Name: name, State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}}}
+}
+
+func terminated(name, reason string, exitCode int32, finished time.Time) v1.ContainerStatus {
+	return v1.ContainerStatus{Name: name, State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+		Reason:     reason,
+		ExitCode:   exitCode,
+		FinishedAt: metav1.NewTime(finished),
+	}}}
+}
+
+func condition(conditionType v1.PodConditionType) v1.PodCondition {
+	return v1.PodCondition{Type: conditionType, Status: v1.ConditionTrue}
+}
+
+func TestPodStatus(t *testing.T) {
+	now := time.Now()
+	deleted := metav1.NewTime(now)
+	initContainers := []v1.Container{{Name: "setup"}, {Name: "proxy"}}
+	tests := []struct {
+		name string
+		pod  v1.Pod
+		want string
+	}{
+		{
+			name: "running",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:             v1.PodRunning,
+				ContainerStatuses: []v1.ContainerStatus{running("app", true)},
+			}},
+			want: "Running",
+		},
+		{
+			name: "pending with a reason",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:             v1.PodPending,
+				ContainerStatuses: []v1.ContainerStatus{waiting("app", "ImagePullBackOff")},
+			}},
+			want: "ImagePullBackOff",
+		},
+		{
+			name: "crash looping",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:             v1.PodRunning,
+				ContainerStatuses: []v1.ContainerStatus{waiting("app", "CrashLoopBackOff")},
+			}},
+			want: "CrashLoopBackOff",
+		},
+		{
+			name: "oom killed",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:             v1.PodFailed,
+				ContainerStatuses: []v1.ContainerStatus{terminated("app", "OOMKilled", 137, now)},
+			}},
+			want: "OOMKilled",
+		},
+		{
+			name: "exit code without a reason",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:             v1.PodFailed,
+				ContainerStatuses: []v1.ContainerStatus{terminated("app", "", 2, now)},
+			}},
+			want: "ExitCode:2",
+		},
+		{
+			name: "completed with a container still running",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:             v1.PodRunning,
+				Conditions:        []v1.PodCondition{condition(v1.PodReady)},
+				ContainerStatuses: []v1.ContainerStatus{running("app", true), terminated("job", "Completed", 0, now)},
+			}},
+			want: "Running",
+		},
+		{
+			name: "completed with a container running but not ready",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:             v1.PodRunning,
+				ContainerStatuses: []v1.ContainerStatus{running("app", true), terminated("job", "Completed", 0, now)},
+			}},
+			want: "NotReady",
+		},
+		{
+			name: "scheduling gated",
+			pod: v1.Pod{Status: v1.PodStatus{
+				Phase:      v1.PodPending,
+				Conditions: []v1.PodCondition{{Type: v1.PodScheduled, Reason: v1.PodReasonSchedulingGated}},
+			}},
+			want: v1.PodReasonSchedulingGated,
+		},
+		{
+			name: "init container running",
+			pod: v1.Pod{
+				Spec: v1.PodSpec{InitContainers: initContainers},
+				Status: v1.PodStatus{
+					Phase:                 v1.PodPending,
+					InitContainerStatuses: []v1.ContainerStatus{terminated("setup", "Completed", 0, now), running("proxy", false)},
+					ContainerStatuses:     []v1.ContainerStatus{waiting("app", "PodInitializing")},
+				},
+			},
+			want: "Init:1/2",
+		},
+		{
+			name: "init container failed",
+			pod: v1.Pod{
+				Spec: v1.PodSpec{InitContainers: initContainers},
+				Status: v1.PodStatus{
+					Phase:                 v1.PodPending,
+					InitContainerStatuses: []v1.ContainerStatus{terminated("setup", "Error", 1, now), waiting("proxy", "PodInitializing")},
+				},
+			},
+			want: "Init:Error",
+		},
+		{
+			name: "init container crash looping",
+			pod: v1.Pod{
+				Spec: v1.PodSpec{InitContainers: initContainers},
+				Status: v1.PodStatus{
+					Phase:                 v1.PodPending,
+					InitContainerStatuses: []v1.ContainerStatus{waiting("setup", "CrashLoopBackOff")},
+				},
+			},
+			want: "Init:CrashLoopBackOff",
+		},
+		{
+			name: "sidecar running in an initialized pod",
+			pod: v1.Pod{
+				Spec: v1.PodSpec{InitContainers: initContainers},
+				Status: v1.PodStatus{
+					Phase:                 v1.PodRunning,
+					Conditions:            []v1.PodCondition{condition(v1.PodInitialized), condition(v1.PodReady)},
+					InitContainerStatuses: []v1.ContainerStatus{terminated("setup", "Completed", 0, now), running("proxy", true)},
+					ContainerStatuses:     []v1.ContainerStatus{running("app", true)},
+				},
+			},
+			want: "Running",
+		},
+		{
+			name: "sidecar with a crashing container",
+			pod: v1.Pod{
+				Spec: v1.PodSpec{InitContainers: initContainers},
+				Status: v1.PodStatus{
+					Phase:                 v1.PodRunning,
+					Conditions:            []v1.PodCondition{condition(v1.PodInitialized)},
+					InitContainerStatuses: []v1.ContainerStatus{terminated("setup", "Completed", 0, now), running("proxy", true)},
+					ContainerStatuses:     []v1.ContainerStatus{waiting("app", "CrashLoopBackOff")},
+				},
+			},
+			want: "CrashLoopBackOff",
+		},
+		{
+			name: "terminating",
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted},
+				Status: v1.PodStatus{
+					Phase:             v1.PodRunning,
+					ContainerStatuses: []v1.ContainerStatus{running("app", true)},
+				},
+			},
+			want: "Terminating",
+		},
+		{
+			name: "node lost",
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted},
+				Status:     v1.PodStatus{Phase: v1.PodRunning, Reason: nodeUnreachablePodReason},
+			},
+			want: "Unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podStatus(tt.pod); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLastTermination(t *testing.T) {
+	// noon keeps both times on today so only the time is printed
+	y, m, d := time.Now().Date()
+	now := time.Date(y, m, d, 12, 0, 0, 0, time.Local)
+	earlier := now.Add(-time.Minute)
+	lastState := func(status v1.ContainerStatus, reason string, exitCode int32, finished time.Time) v1.ContainerStatus {
+		status.LastTerminationState = terminated(status.Name, reason, exitCode, finished).State
+		return status
+	}
+	at := func(reason string, finished time.Time) string {
+		return reason + " at " + finished.Local().Format("15:04")
+	}
+	tests := []struct {
+		name string
+		pod  v1.Pod
+		want string
+	}{
+		{
+			name: "never terminated",
+			pod:  v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{running("app", true)}}},
+			want: "",
+		},
+		{
+			name: "restarted after an oom kill",
+			pod: v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+				lastState(running("app", true), "OOMKilled", 137, now),
+			}}},
+			want: at("OOMKilled", now),
+		},
+		{
+			name: "oom killed and not restarted",
+			pod: v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+				terminated("app", "OOMKilled", 137, now),
+			}}},
+			want: at("OOMKilled", now),
+		},
+		{
+			name: "completed is left out",
+			pod: v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+				terminated("job", "Completed", 0, now),
+			}}},
+			want: "",
+		},
+		{
+			name: "completed init container falls back to its last termination",
+			pod: v1.Pod{Status: v1.PodStatus{InitContainerStatuses: []v1.ContainerStatus{
+				lastState(terminated("setup", "Completed", 0, now), "Error", 1, earlier),
+			}}},
+			want: at("Error", earlier),
+		},
+		{
+			name: "the most recent of the containers",
+			pod: v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+				lastState(running("app", true), "Error", 1, earlier),
+				lastState(running("proxy", true), "OOMKilled", 137, now),
+			}}},
+			want: at("OOMKilled", now),
+		},
+		{
+			name: "signal without a reason",
+			pod: v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+				{Name: "app", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 143, Signal: 15}}},
+			}}},
+			want: "Signal:15",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastTermination(tt.pod); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Stats are the network and filesystem usage of a pod or node from the
+// kubelet summary api
+type Stats struct {
+	// RxRate and TxRate are in bytes per second
+	RxRate float64
+	TxRate float64
+
+	// Ephemeral is the ephemeral storage used by a pod or by all of the
+	// pods on a node. The capacity is only known for nodes, pods have their
+	// limit in MetricValue.EphemeralLimit.
+	Ephemeral         int64
+	EphemeralCapacity int64
+
+	// Rootfs is the writable layer of the containers of a pod or the root
+	// filesystem of a node
+	Rootfs         int64
+	RootfsCapacity int64
+}
+
+// the subset of the kubelet stats/v1alpha1 summary that is used
+type summary struct {
+	Node struct {
+		NodeName string        `json:"nodeName"`
+		CPU      *cpuStats     `json:"cpu"`
+		Memory   *memoryStats  `json:"memory"`
+		Network  *networkStats `json:"network"`
+		Fs       *fsStats      `json:"fs"`
+	} `json:"node"`
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		Containers []struct {
+			Name   string   `json:"name"`
+			Rootfs *fsStats `json:"rootfs"`
+		} `json:"containers"`
+		CPU              *cpuStats     `json:"cpu"`
+		Memory           *memoryStats  `json:"memory"`
+		Network          *networkStats `json:"network"`
+		Volumes          []volumeStats `json:"volume"`
+		EphemeralStorage *fsStats      `json:"ephemeral-storage"`
+	} `json:"pods"`
+}
+
+type cpuStats struct {
+	Time           time.Time `json:"time"`
+	UsageNanoCores *uint64   `json:"usageNanoCores"`
+}
+
+type memoryStats struct {
+	Time            time.Time `json:"time"`
+	WorkingSetBytes *uint64   `json:"workingSetBytes"`
+}
+
+type networkStats struct {
+	Time       time.Time `json:"time"`
+	RxBytes    *uint64   `json:"rxBytes"`
+	TxBytes    *uint64   `json:"txBytes"`
+	Interfaces []struct {
+		RxBytes *uint64 `json:"rxBytes"`
+		TxBytes *uint64 `json:"txBytes"`
+	} `json:"interfaces"`
+}
+
+type fsStats struct {
+	Time           time.Time `json:"time"`
+	AvailableBytes *uint64   `json:"availableBytes"`
+	CapacityBytes  *uint64   `json:"capacityBytes"`
+	UsedBytes      *uint64   `json:"usedBytes"`
+	Inodes         *uint64   `json:"inodes"`
+	InodesUsed     *uint64   `json:"inodesUsed"`
+}
+
+type volumeStats struct {
+	fsStats
+	Name   string `json:"name"`
+	PVCRef *struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"pvcRef"`
+}
+
+// networkCounter is the last reading of the cumulative network bytes
+type networkCounter struct {
+	rx, tx uint64
+	at     time.Time
+}
+
+// nodeStats are the latest stats of a node and its pods by their key
+type nodeStats struct {
+	counters map[string]networkCounter
+	stats    map[string]Stats
+}
+
+// statsCollector reads the summary api of the nodes and keeps the latest
+// stats of every node and its pods. The network counters are turned into
+// rates using the previous reading.
+type statsCollector struct {
+	k *kubernetes.Clientset
+
+	mu    sync.Mutex
+	nodes map[string]*nodeStats
+	// err is why some or all of the nodes couldn't be read in the last collect
+	err error
+}
+
+func newStatsCollector(k *kubernetes.Clientset) *statsCollector {
+	return &statsCollector{k: k, nodes: map[string]*nodeStats{}}
+}
+
+// collect reads the summaries of the nodes the values are on and sets
+// their stats. The stats are extra so failing to read them doesn't fail the
+// refresh, the values just don't get any and the error of the nodes that
+// failed is kept.
+func (c *statsCollector) collect(ctx context.Context, values []MetricValue) {
+	nodes := map[string]bool{}
+	for _, v := range values {
+		if v.Namespace == "" {
+			nodes[v.Name] = true
+		} else if v.Node != "" {
+			nodes[v.Node] = true
+		}
+	}
+	failed, err := eachNode(ctx, nodes, func(ctx context.Context, name string) error {
+		s, err := getSummary(ctx, c.k, name)
+		if err != nil {
+			return err
+		}
+		c.update(name, s)
+		return nil
+	})
+	if err == nil {
+		err = failed
+	}
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	c.apply(values)
+}
+
+// lastErr returns why the last collect couldn't read some or all of the
+// nodes
+func (c *statsCollector) lastErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// getSummary reads the summary api of a node through the api server proxy
+func getSummary(ctx context.Context, k *kubernetes.Clientset, node string) (summary, error) {
+	var s summary
+	data, err := k.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", node, "proxy", "stats", "summary").
+		Do(ctx).Raw()
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// apply sets the latest stats on the values without reading the nodes
+func (c *statsCollector) apply(values []MetricValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range values {
+		node := values[i].Node
+		if values[i].Namespace == "" {
+			node = values[i].Name
+		}
+		n, ok := c.nodes[node]
+		if !ok {
+			continue
+		}
+		if s, ok := n.stats[values[i].Key()]; ok {
+			values[i].Stats = &s
+		}
+	}
+}
+
+func (c *statsCollector) update(name string, s summary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// pods that are gone are dropped along with the old stats
+	prev, ok := c.nodes[name]
+	if !ok {
+		prev = &nodeStats{}
+	}
+	cur := &nodeStats{counters: map[string]networkCounter{}, stats: map[string]Stats{}}
+	c.nodes[name] = cur
+
+	node := Stats{}
+	node.RxRate, node.TxRate = networkRates(prev, cur, name, s.Node.Network)
+	if s.Node.Fs != nil {
+		node.Rootfs = value(s.Node.Fs.UsedBytes)
+		node.RootfsCapacity = value(s.Node.Fs.CapacityBytes)
+		node.EphemeralCapacity = node.RootfsCapacity
+	}
+	for _, pod := range s.Pods {
+		key := pod.PodRef.Namespace + "/" + pod.PodRef.Name
+		stats := Stats{}
+		stats.RxRate, stats.TxRate = networkRates(prev, cur, key, pod.Network)
+		if pod.EphemeralStorage != nil {
+			stats.Ephemeral = value(pod.EphemeralStorage.UsedBytes)
+		}
+		for _, container := range pod.Containers {
+			if container.Rootfs != nil {
+				stats.Rootfs += value(container.Rootfs.UsedBytes)
+			}
+		}
+		node.Ephemeral += stats.Ephemeral
+		cur.stats[key] = stats
+	}
+	cur.stats[name] = node
+}
+
+// networkRates returns the bytes per second received and sent since the
+// previous reading of the same object
+func networkRates(prev, cur *nodeStats, key string, n *networkStats) (float64, float64) {
+	if n == nil {
+		return 0, 0
+	}
+	var rx, tx uint64
+	if len(n.Interfaces) > 0 {
+		for _, i := range n.Interfaces {
+			rx += value64(i.RxBytes)
+			tx += value64(i.TxBytes)
+		}
+	} else {
+		rx, tx = value64(n.RxBytes), value64(n.TxBytes)
+	}
+	last, ok := prev.counters[key]
+	if ok && !n.Time.After(last.at) {
+		// nothing new, keep the last rates
+		cur.counters[key] = last
+		stats := prev.stats[key]
+		return stats.RxRate, stats.TxRate
+	}
+	cur.counters[key] = networkCounter{rx: rx, tx: tx, at: n.Time}
+	if !ok || rx < last.rx || tx < last.tx {
+		return 0, 0
+	}
+	seconds := n.Time.Sub(last.at).Seconds()
+	return float64(rx-last.rx) / seconds, float64(tx-last.tx) / seconds
+}
+
+func value(v *uint64) int64 {
+	return int64(value64(v))
+}
+
+func value64(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
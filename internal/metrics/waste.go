@@ -0,0 +1,263 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/kubectl/pkg/cmd/top"
+)
+
+// usage is tracked for at most this many pods
+const maxWastePods = 5000
+
+// Waste compares what a pod, workload or namespace requested to the usage
+// that was seen for it. Unused is the request minus the peak usage so it
+// can be taken away without going under anything that was observed. The
+// cpu values are in millicores and the memory values in MiB.
+type Waste struct {
+	Namespace string `json:"namespace"`
+	// Name is the pod or the kind and name of the workload, it is empty for
+	// the namespace totals
+	Name    string `json:"name,omitempty"`
+	Pods    int    `json:"pods"`
+	Samples int    `json:"samples"`
+
+	CPURequest int64 `json:"cpuRequest"`
+	CPUAverage int64 `json:"cpuAverage"`
+	CPUPeak    int64 `json:"cpuPeak"`
+	CPUUnused  int64 `json:"cpuUnused"`
+	MemRequest int64 `json:"memRequest"`
+	MemAverage int64 `json:"memAverage"`
+	MemPeak    int64 `json:"memPeak"`
+	MemUnused  int64 `json:"memUnused"`
+}
+
+func (w *Waste) add(o Waste) {
+	w.Pods += o.Pods
+	w.Samples += o.Samples
+	w.CPURequest += o.CPURequest
+	w.CPUAverage += o.CPUAverage
+	w.CPUPeak += o.CPUPeak
+	w.CPUUnused += o.CPUUnused
+	w.MemRequest += o.MemRequest
+	w.MemAverage += o.MemAverage
+	w.MemPeak += o.MemPeak
+	w.MemUnused += o.MemUnused
+}
+
+// WasteReport has the pods, workloads and namespaces that requested the
+// most they didn't use first
+type WasteReport struct {
+	Pods       []Waste `json:"pods"`
+	Workloads  []Waste `json:"workloads"`
+	Namespaces []Waste `json:"namespaces"`
+
+	// FailedPolls is how many times the metrics couldn't be read while the
+	// usage was being collected, those polls are missing from the samples
+	FailedPolls int `json:"failedPolls,omitempty"`
+}
+
+// WasteTracker keeps the requests and the usage seen for every pod
+type WasteTracker struct {
+	pods   map[string]*podUsage
+	failed int
+}
+
+type podUsage struct {
+	last    MetricValue
+	seen    time.Time
+	samples int
+	cpuSum  float64
+	cpuPeak float64
+	memSum  float64
+	memPeak float64
+}
+
+func NewWasteTracker() *WasteTracker {
+	return &WasteTracker{pods: map[string]*podUsage{}}
+}
+
+// Add records the usage of the pods, samples that were already added are
+// skipped so the same reading polled twice doesn't count double
+func (t *WasteTracker) Add(values []MetricValue) {
+	for _, v := range values {
+		if v.MetricsReason != "" {
+			continue
+		}
+		p, ok := t.pods[v.Key()]
+		if !ok {
+			p = &podUsage{}
+			t.pods[v.Key()] = p
+		} else if !v.Timestamp.IsZero() && !v.Timestamp.After(p.last.Timestamp.Time) {
+			continue
+		}
+		cpu, mem := float64(v.CPUCores.MilliValue()), float64(v.MemCores)
+		p.last = v
+		p.seen = time.Now()
+		p.samples++
+		p.cpuSum += cpu
+		p.memSum += mem
+		if cpu > p.cpuPeak {
+			p.cpuPeak = cpu
+		}
+		if mem > p.memPeak {
+			p.memPeak = mem
+		}
+	}
+	EvictOldest(t.pods, maxWastePods, func(p *podUsage) time.Time { return p.seen })
+}
+
+// Report ranks the pods that set requests by their unused cpu or memory,
+// sortBy is cpu or memory
+func (t *WasteTracker) Report(sortBy string) WasteReport {
+	report := WasteReport{Pods: []Waste{}, Workloads: []Waste{}, Namespaces: []Waste{}, FailedPolls: t.failed}
+	workloads := map[string]*Waste{}
+	namespaces := map[string]*Waste{}
+	for _, p := range t.pods {
+		cpuRequest, memRequest := p.last.CPURequest.MilliValue(), p.last.MemRequest
+		if cpuRequest == 0 && memRequest == 0 {
+			continue
+		}
+		w := Waste{
+			Namespace:  p.last.Namespace,
+			Name:       p.last.Name,
+			Pods:       1,
+			Samples:    p.samples,
+			CPURequest: cpuRequest,
+			CPUAverage: int64(p.cpuSum / float64(p.samples)),
+			CPUPeak:    int64(p.cpuPeak),
+			MemRequest: memRequest,
+			MemAverage: int64(p.memSum / float64(p.samples)),
+			MemPeak:    int64(p.memPeak),
+		}
+		if w.CPUPeak < w.CPURequest {
+			w.CPUUnused = w.CPURequest - w.CPUPeak
+		}
+		if w.MemPeak < w.MemRequest {
+			w.MemUnused = w.MemRequest - w.MemPeak
+		}
+		report.Pods = append(report.Pods, w)
+
+		key := w.Namespace + "/" + p.last.Workload
+		if workloads[key] == nil {
+			workloads[key] = &Waste{Namespace: w.Namespace, Name: p.last.Workload}
+		}
+		workloads[key].add(w)
+		if namespaces[w.Namespace] == nil {
+			namespaces[w.Namespace] = &Waste{Namespace: w.Namespace}
+		}
+		namespaces[w.Namespace].add(w)
+	}
+	for _, w := range workloads {
+		report.Workloads = append(report.Workloads, *w)
+	}
+	for _, w := range namespaces {
+		report.Namespaces = append(report.Namespaces, *w)
+	}
+	for _, list := range [][]Waste{report.Pods, report.Workloads, report.Namespaces} {
+		sortWaste(list, sortBy)
+	}
+	return report
+}
+
+func sortWaste(list []Waste, sortBy string) {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		first, second := a.CPUUnused-b.CPUUnused, a.MemUnused-b.MemUnused
+		if sortBy == "memory" {
+			first, second = second, first
+		}
+		if first != 0 {
+			return first > 0
+		}
+		if second != 0 {
+			return second > 0
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
+
+// ValidateWasteSort checks the field the waste report is sorted by
+func ValidateWasteSort(sortBy string) error {
+	if sortBy != "cpu" && sortBy != "memory" {
+		return fmt.Errorf("invalid sort-by provided: %s, must be cpu or memory", sortBy)
+	}
+	return nil
+}
+
+// CollectWaste records the usage of the pods over the duration. Sources
+// that keep history are asked for the duration that just passed, the
+// others are polled every interval until it is over, a poll that fails is
+// skipped and counted in the report. An error is only returned when every
+// poll failed. Watch has to have been started for the pods.
+func (m *MetricsClient) CollectWaste(ctx context.Context, o *top.TopPodOptions, duration, interval, timeout time.Duration) (*WasteTracker, error) {
+	if interval <= 0 {
+		return nil, errors.New("the interval has to be positive")
+	}
+	if err := m.WaitForSync(ctx); err != nil {
+		return nil, err
+	}
+	t := NewWasteTracker()
+	if m.CanBackfill() {
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		end := time.Now()
+		history, err := m.GetPodHistory(callCtx, o, end.Add(-duration), end, interval)
+		if err != nil {
+			return nil, err
+		}
+		for _, step := range history {
+			t.Add(step)
+		}
+		return t, nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	done := time.After(duration)
+	polls := 0
+	var lastErr error
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		values, err := m.GetPodMetrics(callCtx, o)
+		cancel()
+		polls++
+		if err != nil {
+			t.failed++
+			lastErr = err
+		} else {
+			t.Add(values)
+		}
+		select {
+		case <-done:
+			if t.failed == polls {
+				return nil, lastErr
+			}
+			return t, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
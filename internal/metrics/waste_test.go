@@ -0,0 +1,158 @@
+/*
+Copyright © 2020 Chris Kim
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func wastePod(ns, name, workload string, cpuRequest, memRequest, cpu, mem int64, at time.Time) MetricValue {
+	return MetricValue{
+		Name:       name,
+		Namespace:  ns,
+		Workload:   workload,
+		CPURequest: *resource.NewMilliQuantity(cpuRequest, resource.DecimalSI),
+		MemRequest: memRequest,
+		CPUCores:   *resource.NewMilliQuantity(cpu, resource.DecimalSI),
+		MemCores:   mem,
+		Timestamp:  metav1.NewTime(at),
+	}
+}
+
+func TestWasteReport(t *testing.T) {
+	start := time.Now()
+	tracker := NewWasteTracker()
+	tracker.Add([]MetricValue{
+		wastePod("shop", "web-1", "Deployment/web", 1000, 512, 100, 100, start),
+		wastePod("shop", "web-2", "Deployment/web", 1000, 512, 300, 200, start),
+		wastePod("shop", "db-0", "StatefulSet/db", 500, 2048, 400, 1024, start),
+		wastePod("ops", "agent", "DaemonSet/agent", 0, 0, 50, 64, start),
+		wastePod("ops", "pending", "Deployment/api", 200, 256, 0, 0, start),
+	})
+	later := start.Add(time.Minute)
+	second := []MetricValue{
+		wastePod("shop", "web-1", "Deployment/web", 1000, 512, 300, 300, later),
+		// the same reading polled again isn't counted twice
+		wastePod("shop", "web-2", "Deployment/web", 1000, 512, 900, 500, start),
+		wastePod("shop", "db-0", "StatefulSet/db", 500, 2048, 600, 1024, later),
+	}
+	missing := wastePod("ops", "pending", "Deployment/api", 200, 256, 0, 0, later)
+	missing.MetricsReason = "metrics not available yet"
+	tracker.Add(append(second, missing))
+
+	report := tracker.Report("cpu")
+	pods := map[string]Waste{}
+	for _, w := range report.Pods {
+		pods[w.Namespace+"/"+w.Name] = w
+	}
+	if _, ok := pods["ops/agent"]; ok {
+		t.Error("expected the pod without requests to be left out")
+	}
+	want := Waste{
+		Namespace: "shop", Name: "web-1", Pods: 1, Samples: 2,
+		CPURequest: 1000, CPUAverage: 200, CPUPeak: 300, CPUUnused: 700,
+		MemRequest: 512, MemAverage: 200, MemPeak: 300, MemUnused: 212,
+	}
+	if got := pods["shop/web-1"]; got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := pods["shop/web-2"].Samples; got != 1 {
+		t.Errorf("expected the repeated reading to be skipped, got %d samples", got)
+	}
+	// using more than requested leaves nothing unused
+	if got := pods["shop/db-0"]; got.CPUUnused != 0 || got.MemUnused != 1024 {
+		t.Errorf("expected 0m and 1024Mi unused, got %dm and %dMi", got.CPUUnused, got.MemUnused)
+	}
+
+	names := func(list []Waste) []string {
+		out := []string{}
+		for _, w := range list {
+			out = append(out, fmt.Sprintf("%s/%s", w.Namespace, w.Name))
+		}
+		return out
+	}
+	if got, want := names(report.Pods), []string{"shop/web-2", "shop/web-1", "ops/pending", "shop/db-0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected pods %v, got %v", want, got)
+	}
+	if got, want := names(report.Workloads), []string{"shop/Deployment/web", "ops/Deployment/api", "shop/StatefulSet/db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected workloads %v, got %v", want, got)
+	}
+	if web := report.Workloads[0]; web.Pods != 2 || web.CPUUnused != 1400 {
+		t.Errorf("expected the workload to add up its 2 pods, got %+v", web)
+	}
+	if got, want := names(report.Namespaces), []string{"shop/", "ops/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected namespaces %v, got %v", want, got)
+	}
+}
+
+func TestSortWaste(t *testing.T) {
+	list := func() []Waste {
+		return []Waste{
+			{Namespace: "b", Name: "tie", CPUUnused: 100, MemUnused: 10},
+			{Namespace: "a", Name: "tie", CPUUnused: 100, MemUnused: 10},
+			{Namespace: "a", Name: "cpu", CPUUnused: 500, MemUnused: 10},
+			{Namespace: "a", Name: "mem", CPUUnused: 100, MemUnused: 900},
+			{Namespace: "a", Name: "none"},
+		}
+	}
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{sortBy: "cpu", want: []string{"a/cpu", "a/mem", "a/tie", "b/tie", "a/none"}},
+		{sortBy: "memory", want: []string{"a/mem", "a/cpu", "a/tie", "b/tie", "a/none"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			l := list()
+			sortWaste(l, tt.sortBy)
+			got := []string{}
+			for _, w := range l {
+				got = append(got, w.Namespace+"/"+w.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvictOldest(t *testing.T) {
+	now := time.Now()
+	seen := map[string]time.Time{
+		"a": now.Add(-3 * time.Minute),
+		"b": now.Add(-time.Minute),
+		"c": now,
+		"d": now.Add(-2 * time.Minute),
+	}
+	EvictOldest(seen, 4, func(t time.Time) time.Time { return t })
+	if len(seen) != 4 {
+		t.Fatalf("expected nothing to be evicted at the bound, got %d left", len(seen))
+	}
+	EvictOldest(seen, 2, func(t time.Time) time.Time { return t })
+	if _, ok := seen["b"]; !ok || len(seen) != 2 {
+		t.Errorf("expected b and c to be kept, got %v", seen)
+	}
+	if _, ok := seen["c"]; !ok {
+		t.Errorf("expected b and c to be kept, got %v", seen)
+	}
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// how much each new reading moves the baseline, about the last ten
+	// readings count the most
+	ewmaAlpha = 0.2
+
+	// readings aren't flagged until the baseline has seen this many
+	baselineWarmup = 10
+
+	// the band is never narrower than this fraction of the mean so the
+	// small wobbles of a flat series aren't flagged
+	minDeviation = 0.05
+
+	// an object stays flagged in the list for this many readings after an
+	// anomaly so it doesn't flicker away on the next refresh
+	anomalyHold = 3
+)
+
+// baseline is the exponentially weighted moving average and variance of a
+// series
+type baseline struct {
+	mean     float64
+	variance float64
+	n        int
+}
+
+// observe returns whether the reading is outside of the band around the
+// mean and then folds it into the baseline. The sensitivity is the width of
+// the band in standard deviations.
+func (b *baseline) observe(x, sensitivity float64) bool {
+	anomaly := false
+	if b.n >= baselineWarmup {
+		band := math.Max(math.Sqrt(b.variance), math.Max(b.mean*minDeviation, 1))
+		anomaly = math.Abs(x-b.mean) > sensitivity*band
+	}
+	if b.n == 0 {
+		b.mean = x
+	} else {
+		diff := x - b.mean
+		incr := ewmaAlpha * diff
+		b.mean += incr
+		b.variance = (1 - ewmaAlpha) * (b.variance + diff*incr)
+	}
+	b.n++
+	return anomaly
+}
+
+// anomaly returns which of cpu and memory were flagged in the last few
+// readings of the object, it is empty when neither was
+func (h *history) anomaly(key string) string {
+	s, ok := h.data[key]
+	if !ok {
+		return ""
+	}
+	samples := s.samples
+	if len(samples) > anomalyHold {
+		samples = samples[len(samples)-anomalyHold:]
+	}
+	var cpu, mem bool
+	for _, p := range samples {
+		cpu = cpu || p.cpuAnomaly
+		mem = mem || p.memAnomaly
+	}
+	flagged := []string{}
+	if cpu {
+		flagged = append(flagged, "cpu")
+	}
+	if mem {
+		flagged = append(flagged, "memory")
+	}
+	return strings.Join(flagged, ",")
+}
@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBaselineUpdate(t *testing.T) {
+	var b baseline
+	b.observe(10, 3)
+	if b.mean != 10 || b.variance != 0 || b.n != 1 {
+		t.Fatalf("expected the first reading to be the mean, got %+v", b)
+	}
+	// the mean moves a fifth of the way to 20 and the variance is
+	// (1-a)(0 + 10*2)
+	b.observe(20, 3)
+	if !approxEqual(b.mean, 12) || !approxEqual(b.variance, 16) || b.n != 2 {
+		t.Fatalf("expected a mean of 12 and a variance of 16, got %+v", b)
+	}
+	b.observe(12, 3)
+	if !approxEqual(b.mean, 12) || !approxEqual(b.variance, 12.8) {
+		t.Fatalf("expected a mean of 12 and a variance of 12.8, got %+v", b)
+	}
+}
+
+func TestBaselineWarmup(t *testing.T) {
+	var b baseline
+	b.observe(100, 3)
+	for i := 1; i < baselineWarmup; i++ {
+		if b.observe(100000, 3) {
+			t.Fatalf("reading %d was flagged before the baseline warmed up", i+1)
+		}
+	}
+	if b.n != baselineWarmup {
+		t.Fatalf("expected %d readings, got %d", baselineWarmup, b.n)
+	}
+}
+
+func TestBaselineThreshold(t *testing.T) {
+	flat := func() baseline {
+		var b baseline
+		for i := 0; i < baselineWarmup; i++ {
+			b.observe(100, 3)
+		}
+		return b
+	}
+	// a flat series has no variance so the band is 5% of the mean
+	tests := []struct {
+		name        string
+		sensitivity float64
+		x           float64
+		want        bool
+	}{
+		{name: "inside the band", sensitivity: 3, x: 114, want: false},
+		{name: "above the band", sensitivity: 3, x: 116, want: true},
+		{name: "below the band", sensitivity: 3, x: 84, want: true},
+		{name: "wider band", sensitivity: 4, x: 116, want: false},
+		{name: "narrower band", sensitivity: 1, x: 106, want: true},
+		{name: "on the edge", sensitivity: 2, x: 110, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := flat()
+			if got := b.observe(tt.x, tt.sensitivity); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// the band is at least 1 so a series around zero isn't flagged for
+	// every wobble
+	var b baseline
+	for i := 0; i < baselineWarmup; i++ {
+		b.observe(0, 3)
+	}
+	if b.observe(2, 3) {
+		t.Error("expected a reading within the minimum band not to be flagged")
+	}
+	if !b.observe(10, 3) {
+		t.Error("expected a reading past the minimum band to be flagged")
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
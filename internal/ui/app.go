@@ -1,7 +1,7 @@
 package ui
 
 import (
-	"fmt"
+	"context"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -9,113 +9,341 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/chriskim06/kubectl-topui/internal/config"
 	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/kubectl/pkg/cmd/top"
 )
 
 type App struct {
-	client      metrics.MetricsClient
-	cpuData     map[string][][]float64
-	memData     map[string][][]float64
-	xAxisLabels *[]string
-	resource    metrics.Resource
-	options     interface{}
-	current     string
-	tick        time.Ticker
-	interval    time.Duration
-	ready       bool
-	sizeReady   bool
-	err         error
-	height      int
-	width       int
-	itemsPane   List
-	graphsPane  Graphs
-	infoPane    Info
-	loading     *spinner.Model
-}
-
-func New(resource metrics.Resource, interval int, options interface{}, showManagedFields bool, flags *genericclioptions.ConfigFlags) *App {
+	client     metrics.MetricsClient
+	ctx        context.Context
+	cancel     context.CancelFunc
+	timeout    time.Duration
+	infoCancel context.CancelFunc
+	infoSeq    int
+	history    *history
+	resource   metrics.Resource
+	options    interface{}
+	current    string
+	tick       time.Ticker
+	interval   time.Duration
+	ready      bool
+	sizeReady  bool
+	err        error
+	height     int
+	width      int
+	itemsPane  List
+	graphsPane Graphs
+	infoPane   Info
+	statusBar  StatusBar
+	loading    *spinner.Model
+	failures   int
+	rebuilt    time.Time
+	maxRows    int
+	grace      time.Duration
+	flags      *genericclioptions.ConfigFlags
+	settings   Settings
+
+	// values are the rows currently shown and all of them are every row
+	// including the ones past max rows
+	values []metrics.MetricValue
+	all    []metrics.MetricValue
+
+	// waste has the requests and usage of every pod that was seen
+	waste *metrics.WasteTracker
+
+	// neighbors has the recent usage of the pods on the nodes, the report
+	// is open for neighborsNode
+	neighbors     *metrics.NeighborTracker
+	neighborsNode string
+	// neighborsCancel stops the reading of neighborsReading that is in
+	// flight, neighborsSeq tells the replies of the stopped ones apart
+	neighborsCancel  context.CancelFunc
+	neighborsReading string
+	neighborsSeq     int
+	// neighborsSelected is bumped on every selection change so only the
+	// last one within neighborsDebounce starts a reading
+	neighborsSelected int
+
+	// statsKey is the object the statistics are shown for
+	statsKey string
+
+	// pods is the pod view of the autoscaler that was drilled into, it
+	// gets every message until it is closed. child is set on that view and
+	// podsGen counts the views that were opened.
+	pods    *App
+	podsGen int
+	child   bool
+}
+
+// Settings are the command line options shared by every view
+type Settings struct {
+	// Interval is the number of seconds between calls to the metrics api
+	Interval          int
+	ShowManagedFields bool
+	Columns           []string
+	Timeout           time.Duration
+	ChunkSize         int64
+	MaxRows           int
+	// Source is the metrics backend, see metrics.Sources
+	Source string
+	// PrometheusURL overrides the url from the config file
+	PrometheusURL string
+	// Stats reads network and filesystem usage from the kubelet summary
+	// api, it is turned on when one of those columns is shown
+	Stats bool
+	// Throttling reads the cfs throttling of the pods from cadvisor, it is
+	// turned on when the throttling column is shown
+	Throttling bool
+	// GracePeriod is how long objects that were deleted are still shown
+	GracePeriod time.Duration
+	// AnomalySensitivity is how many standard deviations from its moving
+	// average the usage of a pod or node has to be to be flagged
+	AnomalySensitivity float64
+}
+
+// transient errors are retried with an exponential backoff up to this long
+const maxBackoff = time.Minute
+
+// informer changes rebuild the rows at most this often
+const rebuildInterval = time.Second
+
+func New(resource metrics.Resource, options interface{}, settings Settings, flags *genericclioptions.ConfigFlags) (*App, error) {
+	return newApp(resource, options, settings, flags, "")
+}
+
+// newApp creates the app for the resource, the namespace overrides the one
+// from the flags
+func newApp(resource metrics.Resource, options interface{}, settings Settings, flags *genericclioptions.ConfigFlags, namespace string) (*App, error) {
 	conf := config.GetTheme()
-	items := NewList(resource, conf)
+	// the custom metrics configured for the resource are always shown
+	custom := metrics.CustomMetrics(resource)
+	names := []string{}
+	for _, m := range custom {
+		names = append(names, m.Name)
+	}
+	columns := utils.NewColumns(resource, settings.Columns, names)
+	items := NewList(resource, conf, columns)
 	loading := spinner.New(spinner.WithSpinner(spinner.Dot))
-	graphs := NewGraphs(conf)
 	var allNs *bool
-	if resource == metrics.POD {
+	var selector string
+	switch resource {
+	case metrics.POD:
 		allNamespaces := options.(*top.TopPodOptions).AllNamespaces
 		allNs = &allNamespaces
+		selector = options.(*top.TopPodOptions).LabelSelector
+	case metrics.PVC:
+		allNamespaces := options.(*metrics.PVCOptions).AllNamespaces
+		allNs = &allNamespaces
+		selector = options.(*metrics.PVCOptions).LabelSelector
+	case metrics.HPA:
+		allNamespaces := options.(*metrics.HPAOptions).AllNamespaces
+		allNs = &allNamespaces
+		selector = options.(*metrics.HPAOptions).LabelSelector
+	default:
+		selector = options.(*top.TopNodeOptions).Selector
+	}
+	// the stats are only for pods and nodes
+	stats := (resource == metrics.POD || resource == metrics.NODE) && (settings.Stats || utils.NeedsStats(settings.Columns))
+	graphs := NewGraphs(conf, resource, stats, names)
+	prometheus := config.GetPrometheus()
+	if settings.PrometheusURL != "" {
+		prometheus.URL = settings.PrometheusURL
+	}
+	client, err := metrics.New(flags, metrics.Options{
+		ShowManagedFields: settings.ShowManagedFields,
+		AllNamespaces:     allNs,
+		Namespace:         namespace,
+		ChunkSize:         settings.ChunkSize,
+		Source:            settings.Source,
+		Prometheus:        prometheus,
+		Stats:             stats,
+		Throttling:        resource == metrics.POD && (settings.Throttling || utils.NeedsThrottling(settings.Columns)),
+		CustomMetrics:     custom,
+		Timeout:           settings.Timeout,
+		Pricing:           pricing(resource),
+	})
+	if err != nil {
+		return nil, err
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client.Watch(ctx, resource, selector)
 	app := &App{
-		client:      metrics.New(flags, showManagedFields, allNs),
-		resource:    resource,
-		options:     options,
-		cpuData:     map[string][][]float64{},
-		memData:     map[string][][]float64{},
-		xAxisLabels: &[]string{},
-		interval:    time.Duration(interval) * time.Second,
-		itemsPane:   *items,
-		graphsPane:  *graphs,
-		infoPane:    *NewInfo(conf),
-		loading:     &loading,
+		client:     client,
+		ctx:        ctx,
+		cancel:     cancel,
+		timeout:    settings.Timeout,
+		resource:   resource,
+		options:    options,
+		history:    newHistory(),
+		maxRows:    settings.MaxRows,
+		grace:      settings.GracePeriod,
+		flags:      flags,
+		settings:   settings,
+		interval:   time.Duration(settings.Interval) * time.Second,
+		itemsPane:  *items,
+		graphsPane: *graphs,
+		infoPane:   *NewInfo(conf),
+		statusBar:  *NewStatusBar(client.Context(), client.Namespace()),
+		loading:    &loading,
+	}
+	app.history.sensitivity = settings.AnomalySensitivity
+	switch resource {
+	case metrics.POD:
+		app.waste = metrics.NewWasteTracker()
+	case metrics.NODE:
+		app.neighbors = metrics.NewNeighborTracker(neighborWindow)
 	}
-	return app
+	return app, nil
 }
 
 func (a App) Init() tea.Cmd {
-	return tea.Batch(a.loading.Tick, a.updateData)
+	first := a.updateData
+	// only the pod and node usage comes from the source
+	if a.client.CanBackfill() && (a.resource == metrics.POD || a.resource == metrics.NODE) {
+		first = a.backfill
+	}
+	return tea.Batch(a.loading.Tick, first, a.waitForChanges)
 }
 
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if a.pods != nil {
+		if cmd, handled := a.updatePods(msg); handled {
+			return a, cmd
+		}
+	}
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		a.sizeReady = true
-		a.height = msg.Height
-		a.width = msg.Width
-		half := msg.Height / 2
-		third := msg.Width / 3
-		a.itemsPane.SetSize(msg.Width-third, half)
-		a.infoPane.SetSize(third, half)
-		a.graphsPane.SetSize(msg.Width, half)
-		if a.current != "" {
-			a.graphsPane.updateData(a.current, a.cpuData, a.memData, *a.xAxisLabels)
-		}
+		a.resize(msg)
 		return a, cmd
 	case tea.KeyMsg:
 		switch keypress := msg.String(); keypress {
 		case "ctrl+c":
-			return a, tea.Quit
+			return a, a.quit()
 		case "q":
 			if a.err != nil {
-				return a, tea.Quit
+				return a, a.quit()
 			}
 			if !a.itemsPane.focused {
+				a.cancelInfo()
 				a.itemsPane.focused = true
 				a.infoPane.focused = false
 				a.infoPane.SetContent("")
+				a.neighborsNode = ""
+				a.statsKey = ""
 			} else {
-				return a, tea.Quit
+				return a, a.quit()
 			}
 		case "enter":
-			if !a.ready || !a.sizeReady {
+			if !a.ready || !a.sizeReady || a.itemsPane.GetSelected() == "" {
 				return a, nil
 			}
 			if a.itemsPane.focused {
-				a.itemsPane.focused = false
-				var output string
-				var err error
-				if a.resource == metrics.POD {
-					output, err = a.client.GetPod(a.itemsPane.GetSelected(), a.itemsPane.GetNamespace())
-				} else {
-					output, err = a.client.GetNode(a.itemsPane.GetSelected())
+				name, ns := a.itemsPane.GetSelected(), a.itemsPane.GetNamespace()
+				switch a.resource {
+				case metrics.POD:
+					return a, a.infoCmd(func(ctx context.Context) (string, error) {
+						return a.client.GetPod(ctx, name, ns)
+					})
+				case metrics.PVC:
+					return a, a.infoCmd(func(ctx context.Context) (string, error) {
+						return a.client.GetPVC(ctx, name, ns)
+					})
+				case metrics.HPA:
+					return a, a.infoCmd(func(ctx context.Context) (string, error) {
+						return a.client.GetHPA(ctx, name, ns)
+					})
 				}
+				return a, a.infoCmd(func(ctx context.Context) (string, error) {
+					return a.client.GetNode(ctx, name)
+				})
+			}
+		case "d":
+			if !a.ready || !a.sizeReady || a.resource != metrics.NODE || a.itemsPane.GetSelected() == "" {
+				return a, nil
+			}
+			if a.itemsPane.focused {
+				name := a.itemsPane.GetSelected()
+				return a, a.infoCmd(func(ctx context.Context) (string, error) {
+					return a.client.GetNodeDrain(ctx, name)
+				})
+			}
+		case "p":
+			if !a.ready || !a.sizeReady || a.resource != metrics.HPA || !a.itemsPane.focused {
+				return a, nil
+			}
+			return a, a.drillCmd()
+		case "c":
+			if !a.ready || !a.sizeReady || a.resource != metrics.POD || !a.itemsPane.focused {
+				return a, nil
+			}
+			a.cancelInfo()
+			a.itemsPane.focused = false
+			a.infoPane.focused = true
+			a.infoPane.SetText(costText(a.all))
+			return a, nil
+		case "t":
+			key := a.itemsPane.GetKey()
+			if !a.ready || !a.sizeReady || key == "" || !a.itemsPane.focused {
+				return a, nil
+			}
+			a.cancelInfo()
+			a.itemsPane.focused = false
+			a.infoPane.focused = true
+			a.statsKey = key
+			a.showStats()
+			return a, nil
+		case "n":
+			node := a.itemsPane.GetSelected()
+			if !a.ready || !a.sizeReady || a.neighbors == nil || node == "" || !a.itemsPane.focused {
+				return a, nil
+			}
+			a.cancelInfo()
+			a.itemsPane.focused = false
+			a.infoPane.focused = true
+			// the readings taken since the node was selected are shown
+			// right away and a new one is added to them
+			if r, ok := a.neighbors.Report(node); ok {
+				a.infoPane.SetText(neighborsText(r))
+			} else {
+				a.infoPane.SetLoading()
+			}
+			a.neighborsNode = node
+			return a, a.neighborsCmd(node)
+		case "w":
+			if !a.ready || !a.sizeReady || a.waste == nil || !a.itemsPane.focused {
+				return a, nil
+			}
+			sortBy := "cpu"
+			if a.options.(*top.TopPodOptions).SortBy == "memory" {
+				sortBy = "memory"
+			}
+			a.cancelInfo()
+			a.itemsPane.focused = false
+			a.infoPane.focused = true
+			a.infoPane.SetText(wasteText(a.waste.Report(sortBy)))
+			return a, nil
+		case "r":
+			// quotas only make sense for a single namespace
+			ns := a.client.Namespace()
+			if !a.ready || !a.sizeReady || a.resource == metrics.NODE || ns == "" || !a.itemsPane.focused {
+				return a, nil
+			}
+			return a, a.plainInfoCmd(func(ctx context.Context) (string, error) {
+				report, err := a.client.GetQuotaReport(ctx, ns)
 				if err != nil {
-					a.err = err
-					return a, nil
+					return "", err
 				}
-				a.infoPane.focused = true
-				a.infoPane.SetContent(output)
+				return quotaText(report), nil
+			})
+		case "s":
+			if a.graphsPane.pages() < 2 || !a.ready {
+				return a, nil
 			}
+			a.graphsPane.toggleMode()
+			a.graphsPane.updateData(a.current, a.history)
 		case "j", "k", "h", "l", "g", "G", "up", "down", "left", "right", "tab", "shift+tab", "home", "end", "pgup", "pgdown":
 			if !a.ready || !a.sizeReady {
 				return a, nil
@@ -127,23 +355,110 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			a.itemsPane, cmd = a.itemsPane.Update(msg)
 			cmds = append(cmds, cmd)
-			a.current = a.itemsPane.GetSelected()
-			a.graphsPane.updateData(a.current, a.cpuData, a.memData, *a.xAxisLabels)
+			if selected := a.itemsPane.GetKey(); selected != a.current {
+				a.cancelInfo()
+				a.current = selected
+				a.focusThrottling()
+				cmds = append(cmds, a.neighborsSelect())
+			}
+			a.graphsPane.updateData(a.current, a.history)
 		}
-	case tickMsg:
+	case drillMsg:
 		if msg.err != nil {
-			a.err = msg.err
+			a.itemsPane.focused = false
+			a.infoPane.focused = true
+			a.infoPane.SetError(msg.err)
 			return a, nil
 		}
+		return a, a.openPods(msg)
+	case neighborsMsg:
+		a.showNeighbors(msg)
+		return a, nil
+	case neighborsSelectMsg:
+		if msg.seq != a.neighborsSelected {
+			return a, nil
+		}
+		return a, a.neighborsCmd(a.neighborsTarget())
+	case refreshMsg:
+		a.statusBar.refreshing = true
+		return a, a.updateData
+	case infoMsg:
+		// a response for a selection that has since been closed or replaced
+		if msg.seq != a.infoSeq || a.itemsPane.focused {
+			return a, nil
+		}
+		a.infoCancel = nil
+		if msg.err != nil {
+			a.infoPane.SetError(msg.err)
+		} else if msg.plain {
+			a.infoPane.SetText(msg.content)
+		} else {
+			a.infoPane.SetContent(msg.content)
+		}
+	case backfillMsg:
+		if msg.err != nil {
+			a.statusBar.Warn("backfill", msg.err)
+		}
+		for _, m := range msg.history {
+			if t, ok := stepTime(m); ok {
+				a.history.record(t, m)
+				if a.waste != nil {
+					a.waste.Add(m)
+				}
+			}
+		}
+		return a, a.updateData
+	case changedMsg:
+		// the first refresh will pick up whatever changed
+		if !a.ready {
+			return a, a.waitForChanges
+		}
+		// rebuild at most once a second, the changes that come in while
+		// waiting are coalesced and picked up by the next rebuild
+		if wait := time.Until(a.rebuilt.Add(rebuildInterval)); wait > 0 {
+			return a, tea.Tick(wait, func(time.Time) tea.Msg { return changedMsg{} })
+		}
+		a.rebuilt = time.Now()
+		return a, a.cachedData
+	case tickMsg:
+		if msg.cached {
+			if msg.err == nil {
+				a.setCost(msg.m)
+				a.updatePanes(msg)
+			}
+			return a, a.waitForChanges
+		}
+		a.statusBar.refreshing = false
+		if msg.err != nil {
+			// keep showing the last good data and try again later, the
+			// error is only shown in place of the app before the first
+			// successful refresh
+			a.failures++
+			retryIn := a.backoff()
+			a.statusBar.Failure(msg.err, a.failures, retryIn)
+			if !a.ready {
+				a.err = msg.err
+			}
+			return a, a.tickCmd(retryIn)
+		}
+		a.failures = 0
+		a.err = nil
+		a.statusBar.Success(msg.t, msg.latency)
+		for name, err := range msg.warnings {
+			a.statusBar.Warn(name, err)
+		}
+		a.setCost(msg.m)
+		if a.waste != nil {
+			a.waste.Add(msg.m)
+		}
+		a.history.record(msg.t, msg.m)
+		poll := a.pollInterval()
+		a.statusBar.SetCadence(window(msg.m), a.history.cadence, poll)
 		a.ready = true
-		if a.itemsPane.content.SelectedItem() != nil {
-			msg.name = a.itemsPane.GetSelected()
-		}
-		a.current = msg.name
-		var itemsCmd, graphsCmd tea.Cmd
-		a.graphsPane, graphsCmd = a.graphsPane.Update(msg)
-		a.itemsPane, itemsCmd = a.itemsPane.Update(msg)
-		cmds = append(cmds, graphsCmd, itemsCmd, a.tickCmd())
+		cmds = append(cmds, a.updatePanes(msg), a.tickCmd(poll))
+		if node := a.neighborsTarget(); node != "" {
+			cmds = append(cmds, a.neighborsCmd(node))
+		}
 	case spinner.TickMsg:
 		if a.ready && a.sizeReady {
 			a.loading = nil
@@ -156,8 +471,11 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (a App) View() string {
+	if a.pods != nil {
+		return a.pods.View()
+	}
 	if a.err != nil {
-		return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, ErrStyle.Width(a.width/2).Height(a.height/2).Render("ERROR:\n\n"+a.err.Error()))
+		return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, ErrStyle.Width(a.width/2).Height(a.height/2).Render("ERROR:\n\n"+a.err.Error()+"\n\nretrying..."))
 	}
 	if !a.ready || !a.sizeReady {
 		return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, a.loading.View()+"Initializing...")
@@ -166,61 +484,303 @@ func (a App) View() string {
 		lipgloss.Left,
 		a.graphsPane.View(),
 		lipgloss.JoinHorizontal(lipgloss.Top, a.itemsPane.View(), a.infoPane.View()),
+		a.statusBar.View(),
 	)
 }
 
+func (a *App) resize(msg tea.WindowSizeMsg) {
+	a.sizeReady = true
+	a.height = msg.Height
+	a.width = msg.Width
+	half := msg.Height / 2
+	third := msg.Width / 3
+	a.itemsPane.SetSize(msg.Width-third, msg.Height-half-1)
+	a.infoPane.SetSize(third, msg.Height-half-1)
+	a.graphsPane.SetSize(msg.Width, half)
+	a.statusBar.SetSize(msg.Width)
+	if a.current != "" {
+		a.graphsPane.updateData(a.current, a.history)
+	}
+}
+
+// pollInterval is about half of the observed scrape cadence so new samples
+// show up soon after they are taken without asking for the same ones over
+// and over. The interval setting is the lower bound.
+func (a *App) pollInterval() time.Duration {
+	poll := a.history.cadence / 2
+	if poll < a.interval {
+		return a.interval
+	}
+	if poll > maxBackoff {
+		return maxBackoff
+	}
+	return poll
+}
+
+// window returns the interval metrics-server averaged the values over
+func window(m []metrics.MetricValue) time.Duration {
+	for _, v := range m {
+		if v.Window > 0 {
+			return v.Window
+		}
+	}
+	return 0
+}
+
+// updatePanes passes the latest values to the list and graphs
+func (a *App) updatePanes(msg tickMsg) tea.Cmd {
+	msg.history = a.history
+	// every row counts as present, only the rendered ones are cut off
+	msg.gone = a.history.departed(time.Now(), msg.m, a.grace)
+	msg = a.limitRows(msg)
+	a.values = msg.m
+
+	// the list restores the selection first so the graphs follow the same
+	// object even when the rows were reordered
+	var itemsCmd, graphsCmd tea.Cmd
+	a.itemsPane, itemsCmd = a.itemsPane.Update(msg)
+	msg.name = a.itemsPane.GetKey()
+	a.current = msg.name
+	a.focusThrottling()
+	a.graphsPane, graphsCmd = a.graphsPane.Update(msg)
+	if a.statsKey != "" && !a.itemsPane.focused {
+		a.showStats()
+	}
+	return tea.Batch(itemsCmd, graphsCmd)
+}
+
+// focusThrottling reads cadvisor on the node of the selected pod
+func (a *App) focusThrottling() {
+	if a.resource != metrics.POD {
+		return
+	}
+	for _, v := range a.values {
+		if v.Key() == a.current {
+			a.client.FocusThrottling(v.Node)
+			return
+		}
+	}
+}
+
 type tickMsg struct {
-	m           []metrics.MetricValue
-	name        string
-	err         error
-	cpuData     map[string][][]float64
-	memData     map[string][][]float64
-	xAxisLabels []string
+	m       []metrics.MetricValue
+	name    string
+	err     error
+	cached  bool
+	t       time.Time
+	latency time.Duration
+	history *history
+	hidden  int
+	gone    []metrics.MetricValue
+
+	// rest are the rows past max rows, they still have baselines so their
+	// anomalies are listed
+	rest []metrics.MetricValue
+
+	// warnings are why the optional data couldn't be read
+	warnings map[string]error
+}
+
+// backfillMsg has the values from before the app was started
+type backfillMsg struct {
+	history [][]metrics.MetricValue
+	err     error
+}
+
+// refreshMsg triggers the next call to the metrics api
+type refreshMsg struct{}
+
+// changedMsg is sent when the informers see a pod or node change
+type changedMsg struct{}
+
+// infoMsg is the result of fetching the content for the info pane
+type infoMsg struct {
+	seq     int
+	content string
+	err     error
+	// plain content isn't highlighted as yaml
+	plain bool
 }
 
-func (a *App) tickCmd() tea.Cmd {
-	return tea.Tick(a.interval, func(t time.Time) tea.Msg {
-		return a.updateData()
+func (a *App) tickCmd(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return refreshMsg{}
 	})
 }
 
+// infoCmd focuses the info pane and fetches its content off the ui
+// goroutine. Any request still in flight is cancelled first.
+func (a *App) infoCmd(get func(ctx context.Context) (string, error)) tea.Cmd {
+	a.cancelInfo()
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	a.infoCancel = cancel
+	a.infoSeq++
+	seq := a.infoSeq
+	a.itemsPane.focused = false
+	a.infoPane.focused = true
+	a.infoPane.SetLoading()
+	return func() tea.Msg {
+		defer cancel()
+		content, err := get(ctx)
+		return infoMsg{seq: seq, content: content, err: err}
+	}
+}
+
+// plainInfoCmd is infoCmd for content that isn't yaml
+func (a *App) plainInfoCmd(get func(ctx context.Context) (string, error)) tea.Cmd {
+	cmd := a.infoCmd(get)
+	return func() tea.Msg {
+		msg := cmd().(infoMsg)
+		msg.plain = true
+		return msg
+	}
+}
+
+func (a *App) cancelInfo() {
+	if a.infoCancel != nil {
+		a.infoCancel()
+		a.infoCancel = nil
+	}
+}
+
+// quit exits the app, the pod view of an autoscaler goes back to it instead
+func (a *App) quit() tea.Cmd {
+	a.cancel()
+	if a.child {
+		return func() tea.Msg { return backMsg{} }
+	}
+	return tea.Quit
+}
+
+// backoff doubles the refresh interval for every consecutive failure
+func (a App) backoff() time.Duration {
+	d := a.interval
+	for i := 1; i < a.failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// updateData only talks to the api, the results are recorded into the
+// history when the tickMsg is handled in Update
 func (a *App) updateData() tea.Msg {
+	if err := a.client.WaitForSync(a.ctx); err != nil {
+		return tickMsg{err: err}
+	}
 	var err error
 	var m []metrics.MetricValue
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+	start := time.Now()
+	switch a.resource {
+	case metrics.POD:
+		m, err = a.client.GetPodMetrics(ctx, a.options.(*top.TopPodOptions))
+	case metrics.PVC:
+		m, err = a.client.GetPVCMetrics(ctx, a.options.(*metrics.PVCOptions))
+	case metrics.HPA:
+		m, err = a.client.GetHPAMetrics(ctx, a.options.(*metrics.HPAOptions))
+	default:
+		m, err = a.client.GetNodeMetrics(ctx, a.options.(*top.TopNodeOptions))
+	}
+	if err != nil {
+		return tickMsg{err: err}
+	}
+	return tickMsg{
+		m:        m,
+		t:        time.Now(),
+		latency:  time.Since(start),
+		warnings: a.client.Warnings(),
+	}
+}
+
+// backfill gets the history the source already has so the graphs don't
+// start out empty, one step per interval
+func (a *App) backfill() tea.Msg {
+	var err error
+	var history [][]metrics.MetricValue
+	if err := a.client.WaitForSync(a.ctx); err != nil {
+		return backfillMsg{err: err}
+	}
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+	end := time.Now()
+	start := end.Add(-a.interval * (maxDataPoints - 1))
 	if a.resource == metrics.POD {
-		m, err = a.client.GetPodMetrics(a.options.(*top.TopPodOptions))
+		history, err = a.client.GetPodHistory(ctx, a.options.(*top.TopPodOptions), start, end, a.interval)
 	} else {
-		m, err = a.client.GetNodeMetrics(a.options.(*top.TopNodeOptions))
+		history, err = a.client.GetNodeHistory(ctx, a.options.(*top.TopNodeOptions), start, end, a.interval)
 	}
 	if err != nil {
-		return tickMsg{err: err}
+		// a failed backfill only means the graphs start out empty, the
+		// error is shown in the status bar
+		return backfillMsg{err: err}
 	}
-	if len(*a.xAxisLabels) == 50 {
-		*a.xAxisLabels = (*a.xAxisLabels)[1:]
-	}
-	t := time.Now()
-	*a.xAxisLabels = append(*a.xAxisLabels, fmt.Sprintf("%02d:%02d:%02d", t.Hour(), t.Minute(), t.Second()))
-	for _, metric := range m {
-		name := metric.Name
-		if a.cpuData[name] == nil || a.memData[name] == nil {
-			a.cpuData[name] = [][]float64{{}, {}}
-			a.memData[name] = [][]float64{{}, {}}
-		} else if len(a.cpuData[name][0]) == 50 {
-			a.cpuData[name][0] = a.cpuData[name][0][1:]
-			a.cpuData[name][1] = a.cpuData[name][1][1:]
-			a.memData[name][0] = a.memData[name][0][1:]
-			a.memData[name][1] = a.memData[name][1][1:]
-		}
-		a.cpuData[name][0] = append(a.cpuData[name][0], float64(metric.CPULimit.MilliValue()))
-		a.cpuData[name][1] = append(a.cpuData[name][1], float64(metric.CPUCores.MilliValue()))
-		a.memData[name][0] = append(a.memData[name][0], float64(metric.MemLimit))
-		a.memData[name][1] = append(a.memData[name][1], float64(metric.MemCores))
+	return backfillMsg{history: history}
+}
+
+// stepTime is when the values of a backfilled step were taken
+func stepTime(m []metrics.MetricValue) (time.Time, bool) {
+	for _, v := range m {
+		if !v.Timestamp.IsZero() {
+			return v.Timestamp.Time, true
+		}
 	}
-	return tickMsg{
-		m:           m,
-		name:        m[0].Name,
-		cpuData:     a.cpuData,
-		memData:     a.memData,
-		xAxisLabels: *a.xAxisLabels,
+	return time.Time{}, false
+}
+
+func (a *App) waitForChanges() tea.Msg {
+	select {
+	case <-a.client.Changes():
+		return changedMsg{}
+	case <-a.ctx.Done():
+		return nil
+	}
+}
+
+// cachedData rebuilds the values with the latest pod or node metadata
+// without calling the metrics api
+func (a *App) cachedData() tea.Msg {
+	var err error
+	var m []metrics.MetricValue
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+	switch a.resource {
+	case metrics.POD:
+		m, err = a.client.CachedPodMetrics(ctx, a.options.(*top.TopPodOptions))
+	case metrics.PVC:
+		m, err = a.client.CachedPVCMetrics(ctx, a.options.(*metrics.PVCOptions))
+	case metrics.HPA:
+		// the autoscalers only come from the informer anyway
+		m, err = a.client.GetHPAMetrics(ctx, a.options.(*metrics.HPAOptions))
+	default:
+		m, err = a.client.CachedNodeMetrics(ctx, a.options.(*top.TopNodeOptions))
+	}
+	return tickMsg{m: m, err: err, cached: true}
+}
+
+// limitRows caps the number of rows that are rendered
+func (a App) limitRows(msg tickMsg) tickMsg {
+	if a.maxRows > 0 && len(msg.m) > a.maxRows {
+		msg.hidden = len(msg.m) - a.maxRows
+		msg.rest = msg.m[a.maxRows:]
+		msg.m = msg.m[:a.maxRows]
+	}
+	return msg
+}
+
+// setCost keeps every row for the cost breakdown and shows their total
+func (a *App) setCost(m []metrics.MetricValue) {
+	a.all = m
+	a.statusBar.cost = totalCost(m)
+}
+
+// pricing returns the configured prices, only pods are priced
+func pricing(resource metrics.Resource) config.Pricing {
+	if resource != metrics.POD {
+		return config.Pricing{}
 	}
+	return config.GetPricing()
 }
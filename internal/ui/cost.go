@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"k8s.io/cli-runtime/pkg/printers"
+)
+
+// totalCost sums the cost of the pods, it is nil when pricing isn't
+// configured
+func totalCost(values []metrics.MetricValue) *metrics.Cost {
+	var total *metrics.Cost
+	for _, v := range values {
+		if v.Cost == nil {
+			continue
+		}
+		if total == nil {
+			total = &metrics.Cost{}
+		}
+		*total = total.Add(*v.Cost)
+	}
+	return total
+}
+
+// costText renders the cost of every namespace with its workloads under
+// it, the most expensive by requests first
+func costText(values []metrics.MetricValue) string {
+	namespaces := map[string]*metrics.Cost{}
+	workloads := map[string]map[string]*metrics.Cost{}
+	for _, v := range values {
+		if v.Cost == nil {
+			continue
+		}
+		if namespaces[v.Namespace] == nil {
+			namespaces[v.Namespace] = &metrics.Cost{}
+			workloads[v.Namespace] = map[string]*metrics.Cost{}
+		}
+		*namespaces[v.Namespace] = namespaces[v.Namespace].Add(*v.Cost)
+		if workloads[v.Namespace][v.Workload] == nil {
+			workloads[v.Namespace][v.Workload] = &metrics.Cost{}
+		}
+		*workloads[v.Namespace][v.Workload] = workloads[v.Namespace][v.Workload].Add(*v.Cost)
+	}
+	if len(namespaces) == 0 {
+		return "pricing is not configured"
+	}
+
+	var b bytes.Buffer
+	w := printers.GetNewTabWriter(&b)
+	fmt.Fprintln(w, "ESTIMATED COST\tREQUESTED /H\tUSED /H\tREQUESTED /MO")
+	row := func(name string, c metrics.Cost) {
+		fmt.Fprintf(w, "%s\t$%.3f\t$%.3f\t$%.2f\n", name, c.Requested, c.Used, c.Monthly().Requested)
+	}
+	total := totalCost(values)
+	row("total", *total)
+	for _, ns := range byRequested(namespaces) {
+		row(ns, *namespaces[ns])
+		for _, name := range byRequested(workloads[ns]) {
+			row("  "+name, *workloads[ns][name])
+		}
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func byRequested(costs map[string]*metrics.Cost) []string {
+	names := make([]string, 0, len(costs))
+	for name := range costs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := costs[names[i]], costs[names[j]]
+		if a.Requested != b.Requested {
+			return a.Requested > b.Requested
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
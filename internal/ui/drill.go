@@ -0,0 +1,132 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"k8s.io/kubectl/pkg/cmd/top"
+)
+
+// drillMsg has the selector of the pods scaled by an autoscaler
+type drillMsg struct {
+	scope     string
+	namespace string
+	selector  string
+	err       error
+}
+
+// backMsg is sent when the pod view of an autoscaler is closed
+type backMsg struct{}
+
+// childMsg wraps the messages of the pod view so they aren't mistaken for
+// the ones of the app that opened it. gen is the pod view they came from so
+// the ones still in flight from a closed view don't reach the next one.
+type childMsg struct {
+	gen int
+	msg tea.Msg
+}
+
+// drillCmd looks up the pods the selected autoscaler scales
+func (a *App) drillCmd() tea.Cmd {
+	key := a.itemsPane.GetKey()
+	var target *metrics.Autoscaler
+	var namespace string
+	for _, v := range a.values {
+		if v.Key() == key && v.Autoscaler != nil {
+			target, namespace = v.Autoscaler, v.Namespace
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	return func() tea.Msg {
+		defer cancel()
+		selector, err := a.client.GetHPATargetSelector(ctx, target.APIVersion, target.Kind, target.Target, namespace)
+		return drillMsg{
+			scope:     fmt.Sprintf("pods of %s", target.Reference),
+			namespace: namespace,
+			selector:  selector,
+			err:       err,
+		}
+	}
+}
+
+// openPods starts a pod view of the autoscaler's target that replaces this
+// one until it is closed. An error creating it is shown in the info pane.
+func (a *App) openPods(msg drillMsg) tea.Cmd {
+	options := &top.TopPodOptions{LabelSelector: msg.selector}
+	settings := a.settings
+	settings.Columns = nil
+	pods, err := newApp(metrics.POD, options, settings, a.flags, msg.namespace)
+	if err != nil {
+		a.itemsPane.focused = false
+		a.infoPane.focused = true
+		a.infoPane.SetError(err)
+		return nil
+	}
+	a.pods = pods
+	a.pods.child = true
+	a.pods.statusBar.scope = msg.scope + " (q to go back)"
+	a.podsGen++
+	_, cmd := a.pods.Update(tea.WindowSizeMsg{Width: a.width, Height: a.height})
+	return tea.Batch(a.wrapChild(a.pods.Init()), a.wrapChild(cmd))
+}
+
+// updatePods passes the messages to the pod view while it is open. The ones
+// that belong to this app are left for it to handle.
+func (a *App) updatePods(msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case childMsg:
+		if msg.gen != a.podsGen {
+			return nil, true
+		}
+		if _, ok := msg.msg.(backMsg); ok {
+			a.pods = nil
+			a.graphsPane.updateData(a.current, a.history)
+			return nil, true
+		}
+		_, cmd := a.pods.Update(msg.msg)
+		return a.wrapChild(cmd), true
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			a.pods.cancel()
+			return a.quit(), true
+		}
+		_, cmd := a.pods.Update(msg)
+		return a.wrapChild(cmd), true
+	case tea.WindowSizeMsg:
+		// both views keep their size so going back doesn't need a resize
+		_, cmd := a.pods.Update(msg)
+		a.resize(msg)
+		return a.wrapChild(cmd), true
+	}
+	return nil, false
+}
+
+// wrapChild marks the messages of the pod view's commands as its own
+func (a *App) wrapChild(cmd tea.Cmd) tea.Cmd {
+	return wrapGen(cmd, a.podsGen)
+}
+
+func wrapGen(cmd tea.Cmd, gen int) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			wrapped := make(tea.BatchMsg, 0, len(batch))
+			for _, c := range batch {
+				wrapped = append(wrapped, wrapGen(c, gen))
+			}
+			return wrapped
+		}
+		if msg == nil {
+			return nil
+		}
+		return childMsg{gen: gen, msg: msg}
+	}
+}
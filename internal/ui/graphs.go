@@ -8,6 +8,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	plot "github.com/chriskim06/bubble-plot"
 	"github.com/chriskim06/kubectl-topui/internal/config"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
 )
 
 var (
@@ -16,28 +17,42 @@ var (
 )
 
 type Graphs struct {
-	Height  int
-	Width   int
-	extra   int
-	name    string
-	cpuData map[string][][]float64
-	memData map[string][][]float64
-	labels  []string
+	Height   int
+	Width    int
+	extra    int
+	name     string
+	resource metrics.Resource
+	history  *history
+	reasons  map[string]string
+	conf     config.Colors
+	// scaledOn is the first metric of each autoscaler
+	scaledOn map[string]string
+
+	// page is which of the sets of graphs is shown, the first one is cpu
+	// and memory followed by network and storage when stats are read and
+	// then the custom metrics two at a time
+	page    int
+	stats   bool
+	custom  []string
 	cpuPlot *plot.Model
 	memPlot *plot.Model
 }
 
-func NewGraphs(conf config.Colors) *Graphs {
+func NewGraphs(conf config.Colors, resource metrics.Resource, stats bool, custom []string) *Graphs {
 	options := []plot.Option{
-		plot.WithMaxDataPoints(50),
+		plot.WithMaxDataPoints(maxDataPoints),
 		plot.WithAxisColor(conf.Axis),
 		plot.WithLabelColor(conf.Labels),
 	}
-	cpuPlot := plot.New(append(options, plot.WithLineColors([]int{conf.CPULimit, conf.CPUUsage}))...)
+	cpuPlot := plot.New(append(options, plot.WithLineColors([]int{conf.CPULimit, conf.CPUUsage, conf.CPUThrottled}))...)
 	memPlot := plot.New(append(options, plot.WithLineColors([]int{conf.MemLimit, conf.MemUsage}))...)
 	return &Graphs{
-		cpuPlot: cpuPlot,
-		memPlot: memPlot,
+		resource: resource,
+		conf:     conf,
+		stats:    stats,
+		custom:   custom,
+		cpuPlot:  cpuPlot,
+		memPlot:  memPlot,
 	}
 }
 
@@ -50,7 +65,25 @@ func (g *Graphs) Update(msg tea.Msg) (Graphs, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		g.SetSize(msg.Width, msg.Height)
 	case tickMsg:
-		g.updateData(msg.name, msg.cpuData, msg.memData, msg.xAxisLabels)
+		g.reasons = map[string]string{}
+		g.scaledOn = map[string]string{}
+		for _, m := range msg.m {
+			if m.Autoscaler != nil && len(m.Autoscaler.Metrics) > 0 {
+				metric := m.Autoscaler.Metrics[0]
+				g.scaledOn[m.Key()] = strings.ToUpper(metric.Name)
+				if metric.Utilization {
+					g.scaledOn[m.Key()] += " %"
+				}
+			}
+		}
+		for _, values := range [][]metrics.MetricValue{msg.m, msg.gone} {
+			for _, m := range values {
+				if m.MetricsReason != "" {
+					g.reasons[m.Key()] = m.MetricsReason
+				}
+			}
+		}
+		g.updateData(msg.name, msg.history)
 	}
 	return *g, nil
 }
@@ -69,19 +102,119 @@ func (g *Graphs) SetSize(width, height int) {
 	g.extra = width % 2
 }
 
-func (g *Graphs) updateData(name string, cpuData, memData map[string][][]float64, labels []string) {
-	g.name = name
-	g.cpuData = cpuData
-	g.memData = memData
-	g.labels = labels
+// pages returns how many sets of graphs there are to switch between
+func (g *Graphs) pages() int {
+	n := 1 + (len(g.custom)+1)/2
+	if g.stats {
+		n++
+	}
+	return n
+}
+
+// toggleMode switches to the next set of graphs
+func (g *Graphs) toggleMode() {
+	g.page = (g.page + 1) % g.pages()
+}
+
+// io returns whether network and storage are shown
+func (g *Graphs) io() bool {
+	return g.stats && g.page == 1
+}
+
+// customPage returns the custom metrics shown on the current page
+func (g *Graphs) customPage() []string {
+	i := g.page - 1
+	if g.stats {
+		i--
+	}
+	if i < 0 {
+		return nil
+	}
+	names := g.custom[i*2:]
+	if len(names) > 2 {
+		names = names[:2]
+	}
+	return names
+}
+
+func (g *Graphs) updateData(key string, h *history) {
+	g.name = key[strings.LastIndex(key, "/")+1:]
+	g.history = h
+	data := h.get(key)
+	left, right := data.cpu, data.mem
 	g.cpuPlot.Title = fmt.Sprintf("CPU - %s", g.name)
 	g.memPlot.Title = fmt.Sprintf("MEM - %s", g.name)
+	cpuColors := []int{g.conf.CPULimit, g.conf.CPUUsage, g.conf.CPUThrottled}
+	memColors := []int{g.conf.MemLimit, g.conf.MemUsage}
+	if custom := g.customPage(); len(custom) > 0 {
+		// custom metrics have a single line drawn in the usage colors
+		cpuColors, memColors = []int{g.conf.CPUUsage}, []int{g.conf.MemUsage}
+		left, right = [][]float64{data.custom[custom[0]]}, nil
+		g.cpuPlot.Title = fmt.Sprintf("%s - %s", strings.ToUpper(custom[0]), g.name)
+		g.memPlot.Title = ""
+		if len(custom) > 1 {
+			right = [][]float64{data.custom[custom[1]]}
+			g.memPlot.Title = fmt.Sprintf("%s - %s", strings.ToUpper(custom[1]), g.name)
+		}
+	} else if g.resource == metrics.HPA {
+		g.cpuPlot.Title = fmt.Sprintf("REPLICAS - %s", g.name)
+		scaledOn, ok := g.scaledOn[key]
+		if !ok {
+			scaledOn = "METRIC"
+		}
+		g.memPlot.Title = fmt.Sprintf("%s - %s", scaledOn, g.name)
+	} else if g.resource == metrics.PVC {
+		g.cpuPlot.Title = fmt.Sprintf("USED MiB - %s", g.name)
+		g.memPlot.Title = fmt.Sprintf("INODES - %s", g.name)
+	} else if g.io() {
+		left, right = data.net, data.disk
+		g.cpuPlot.Title = fmt.Sprintf("NET RX/TX KiB/s - %s", g.name)
+		g.memPlot.Title = fmt.Sprintf("EPHEMERAL/ROOTFS MiB - %s", g.name)
+	}
+	if data.throttled != nil && g.page == 0 {
+		g.cpuPlot.Title += fmt.Sprintf(" (throttled %.1f%%)", *data.throttled)
+	}
+	if g.page == 0 {
+		// the anomalies are drawn as spikes up to the usage at each point
+		// that was flagged
+		if data.cpuAnomalies != nil {
+			left = append(append([][]float64{}, left...), data.cpuAnomalies)
+			cpuColors = append(append([]int{}, cpuColors[:len(left)-1]...), g.conf.Anomaly)
+			g.cpuPlot.Title += " (anomaly)"
+		}
+		if data.memAnomalies != nil {
+			right = append(append([][]float64{}, right...), data.memAnomalies)
+			memColors = append(append([]int{}, memColors[:len(right)-1]...), g.conf.Anomaly)
+			g.memPlot.Title += " (anomaly)"
+		}
+	}
+	suffix := ""
+	if reason, ok := g.reasons[key]; ok {
+		suffix += fmt.Sprintf(" (%s)", reason)
+	}
+	for _, p := range []*plot.Model{g.cpuPlot, g.memPlot} {
+		if p.Title != "" {
+			p.Title += suffix
+		}
+	}
+	setLineColors(g.cpuPlot, cpuColors)
+	setLineColors(g.memPlot, memColors)
 	g.cpuPlot.Update(plot.GraphUpdateMsg{
-		Data:   g.cpuData[g.name],
-		Labels: g.labels,
+		Data:   left,
+		Labels: data.labels,
 	})
 	g.memPlot.Update(plot.GraphUpdateMsg{
-		Data:   g.memData[g.name],
-		Labels: g.labels,
+		Data:   right,
+		Labels: data.labels,
 	})
 }
+
+// setLineColors changes the colors of the lines, the plot only picks them up
+// when it is resized so it is resized to the same size
+func setLineColors(p *plot.Model, colors []int) {
+	if fmt.Sprint(p.Styles.LineColors) == fmt.Sprint(colors) {
+		return
+	}
+	p.Styles.LineColors = colors
+	p.SetSize(tea.WindowSizeMsg{Width: p.Width, Height: p.Height})
+}
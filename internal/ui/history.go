@@ -0,0 +1,304 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+)
+
+const (
+	// the number of points kept for each graph
+	maxDataPoints = 50
+
+	// history is kept for at most this many objects
+	maxHistoryObjects = 5000
+)
+
+// sample is a single reading of an object. slot is the refresh it was
+// recorded in and at is when metrics-server actually took it.
+type sample struct {
+	slot     time.Time
+	at       time.Time
+	cpuLimit float64
+	cpu      float64
+	memLimit float64
+	mem      float64
+
+	// network in KiB/s and storage in MiB, only set with the summary api
+	rx        float64
+	tx        float64
+	ephemeral float64
+	rootfs    float64
+
+	// throttled is the percent of cfs periods that were throttled, only set
+	// when cadvisor is being read
+	throttled *float64
+
+	// custom are the values of the configured custom metrics by name
+	custom map[string]float64
+
+	// cpuAnomaly and memAnomaly are set when the usage was outside of the
+	// band around the object's baseline
+	cpuAnomaly bool
+	memAnomaly bool
+}
+
+// series is the limit and usage history of a single object. last and seen
+// are the values and time it was last listed, with or without a sample.
+type series struct {
+	samples []sample
+	last    metrics.MetricValue
+	seen    time.Time
+	cpuBase baseline
+	memBase baseline
+}
+
+// history holds the graph data for every object that has been displayed.
+// slots are the times of the refreshes that had new samples, every object
+// keeps the samples of the same last slots.
+type history struct {
+	data  map[string]*series
+	slots []time.Time
+
+	// cadence is how often metrics-server has been observed to take new
+	// samples, zero until an object has two of them
+	cadence time.Duration
+
+	// sensitivity is how many standard deviations from its baseline the
+	// usage of a pod or node has to be to be flagged, 0 turns it off
+	sensitivity float64
+}
+
+func newHistory() *history {
+	return &history{
+		data:  map[string]*series{},
+		slots: []time.Time{},
+	}
+}
+
+// record adds the values that metrics-server took since the last call. The
+// ones it already returned before are skipped and a slot is only added when
+// there is at least one new sample. Every object is marked as seen even
+// without a sample so the ones that never had metrics are still shown as
+// gone.
+func (h *history) record(t time.Time, m []metrics.MetricValue) {
+	fresh := []metrics.MetricValue{}
+	intervals := []time.Duration{}
+	for _, metric := range m {
+		s, ok := h.data[metric.Key()]
+		if !ok {
+			s = &series{}
+			h.data[metric.Key()] = s
+		}
+		s.last = metric
+		s.seen = t
+		if metric.MetricsReason != "" {
+			continue
+		}
+		if len(s.samples) > 0 && !metric.Timestamp.IsZero() {
+			prev := s.samples[len(s.samples)-1].at
+			if !metric.Timestamp.After(prev) {
+				continue
+			}
+			intervals = append(intervals, metric.Timestamp.Sub(prev))
+		}
+		fresh = append(fresh, metric)
+	}
+	metrics.EvictOldest(h.data, maxHistoryObjects, func(s *series) time.Time { return s.seen })
+	if len(fresh) == 0 {
+		return
+	}
+	if len(intervals) > 0 {
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+		h.cadence = intervals[len(intervals)/2]
+	}
+
+	if len(h.slots) == maxDataPoints {
+		h.slots = h.slots[1:]
+	}
+	h.slots = append(h.slots, t)
+	for _, metric := range fresh {
+		s := h.data[metric.Key()]
+		at := metric.Timestamp.Time
+		if at.IsZero() {
+			at = t
+		}
+		p := sample{
+			slot:     t,
+			at:       at,
+			cpuLimit: float64(metric.CPULimit.MilliValue()),
+			cpu:      float64(metric.CPUCores.MilliValue()),
+			memLimit: float64(metric.MemLimit),
+			mem:      float64(metric.MemCores),
+		}
+		if metric.Volume != nil {
+			// claims graph the used bytes against the capacity and the
+			// used inodes against the total
+			p.cpuLimit = float64(metric.Volume.Capacity) / float64(metrics.DIVISOR)
+			p.cpu = float64(metric.Volume.Used) / float64(metrics.DIVISOR)
+			p.memLimit = float64(metric.Volume.Inodes)
+			p.mem = float64(metric.Volume.InodesUsed)
+		}
+		if a := metric.Autoscaler; a != nil {
+			// autoscalers graph the desired against the current replicas and
+			// the target against the current value of their first metric
+			p.cpuLimit = float64(a.DesiredReplicas)
+			p.cpu = float64(a.CurrentReplicas)
+			if len(a.Metrics) > 0 {
+				p.memLimit = a.Metrics[0].TargetValue
+				p.mem = a.Metrics[0].CurrentValue
+			}
+		}
+		if len(metric.Custom) > 0 {
+			p.custom = metric.Custom
+		}
+		if metric.Throttling != nil {
+			throttled := metric.Throttling.Percent()
+			p.throttled = &throttled
+		}
+		if h.sensitivity > 0 && metric.Volume == nil && metric.Autoscaler == nil {
+			p.cpuAnomaly = s.cpuBase.observe(p.cpu, h.sensitivity)
+			p.memAnomaly = s.memBase.observe(p.mem, h.sensitivity)
+		}
+		if metric.Stats != nil {
+			p.rx = metric.Stats.RxRate / 1024
+			p.tx = metric.Stats.TxRate / 1024
+			p.ephemeral = float64(metric.Stats.Ephemeral) / float64(metrics.DIVISOR)
+			p.rootfs = float64(metric.Stats.Rootfs) / float64(metrics.DIVISOR)
+		}
+		s.samples = append(s.samples, p)
+	}
+	h.trim()
+}
+
+// trim drops the samples that are older than the oldest slot
+func (h *history) trim() {
+	oldest := h.slots[0]
+	for _, s := range h.data {
+		i := 0
+		for i < len(s.samples) && s.samples[i].slot.Before(oldest) {
+			i++
+		}
+		s.samples = s.samples[i:]
+	}
+}
+
+// departed returns the last values of the objects that have history but
+// are no longer in the current values so they can still be looked at. The
+// ones that have been gone longer than the grace period are dropped.
+func (h *history) departed(now time.Time, current []metrics.MetricValue, grace time.Duration) []metrics.MetricValue {
+	present := make(map[string]bool, len(current))
+	for _, m := range current {
+		present[m.Key()] = true
+	}
+	gone := []metrics.MetricValue{}
+	for key, s := range h.data {
+		if present[key] {
+			continue
+		}
+		if now.Sub(s.seen) > grace {
+			delete(h.data, key)
+			continue
+		}
+		m := s.last
+		m.MetricsReason = fmt.Sprintf("gone %s ago", now.Sub(s.seen).Round(time.Second))
+		if m.Namespace != "" {
+			m.Status = "Gone"
+		}
+		gone = append(gone, m)
+	}
+	sort.Slice(gone, func(i, j int) bool {
+		return gone[i].Key() < gone[j].Key()
+	})
+	return gone
+}
+
+// graph is the data of one object laid out on the shared slots
+type graph struct {
+	cpu    [][]float64
+	mem    [][]float64
+	net    [][]float64
+	disk   [][]float64
+	labels []string
+
+	// throttled is the latest throttling percent when it is known
+	throttled *float64
+
+	// custom has a single line for each custom metric by name
+	custom map[string][]float64
+
+	// cpuAnomalies and memAnomalies are the usage at the points that were
+	// flagged and zero everywhere else, they are nil without any
+	cpuAnomalies []float64
+	memAnomalies []float64
+}
+
+// get returns the cpu and memory data for the object. Only the samples it
+// has are plotted since the graphs can't draw a gap, a missing refresh would
+// otherwise show up as a drop to zero. The labels are when the samples were
+// taken so a gap shows up as a jump in them.
+func (h *history) get(key string) graph {
+	s, ok := h.data[key]
+	if !ok || len(s.samples) == 0 {
+		return graph{}
+	}
+	points := s.samples
+
+	lines := func(n int) [][]float64 {
+		l := make([][]float64, n)
+		for i := range l {
+			l[i] = make([]float64, len(points))
+		}
+		return l
+	}
+	// the throttling is drawn as a third cpu line scaled so that reaching
+	// the limit line means every period was throttled
+	cpuLines := 2
+	var throttled *float64
+	for _, p := range points {
+		if p.throttled != nil {
+			cpuLines = 3
+			throttled = p.throttled
+		}
+	}
+	g := graph{cpu: lines(cpuLines), mem: lines(2), net: lines(2), disk: lines(2), labels: make([]string, len(points)), throttled: throttled, custom: map[string][]float64{}}
+	for _, p := range points {
+		for name := range p.custom {
+			if _, ok := g.custom[name]; !ok {
+				g.custom[name] = make([]float64, len(points))
+			}
+		}
+	}
+	for i, p := range points {
+		g.labels[i] = timeLabel(p.at)
+		g.cpu[0][i], g.cpu[1][i] = p.cpuLimit, p.cpu
+		if p.throttled != nil {
+			g.cpu[2][i] = p.cpuLimit * *p.throttled / 100
+		}
+		g.mem[0][i], g.mem[1][i] = p.memLimit, p.mem
+		if p.cpuAnomaly {
+			if g.cpuAnomalies == nil {
+				g.cpuAnomalies = make([]float64, len(points))
+			}
+			g.cpuAnomalies[i] = p.cpu
+		}
+		if p.memAnomaly {
+			if g.memAnomalies == nil {
+				g.memAnomalies = make([]float64, len(points))
+			}
+			g.memAnomalies[i] = p.mem
+		}
+		g.net[0][i], g.net[1][i] = p.rx, p.tx
+		g.disk[0][i], g.disk[1][i] = p.ephemeral, p.rootfs
+		for name, v := range p.custom {
+			g.custom[name][i] = v
+		}
+	}
+	return g
+}
+
+func timeLabel(t time.Time) string {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hour(), t.Minute(), t.Second())
+}
@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeparted(t *testing.T) {
+	start := time.Now()
+	grace := time.Minute
+	h := newHistory()
+	running := metrics.MetricValue{Name: "web", Namespace: "default", CPUCores: *resource.NewMilliQuantity(100, resource.DecimalSI), Timestamp: metav1.NewTime(start)}
+	pending := metrics.MetricValue{Name: "job", Namespace: "default", MetricsReason: "metrics not available yet"}
+	h.record(start, []metrics.MetricValue{running, pending})
+
+	later := start.Add(30 * time.Second)
+	gone := h.departed(later, nil, grace)
+	if len(gone) != 2 {
+		t.Fatalf("expected both pods to be gone, got %d", len(gone))
+	}
+	for _, m := range gone {
+		if m.Status != "Gone" || m.MetricsReason != "gone 30s ago" {
+			t.Errorf("%s: expected it to be gone 30s ago, got %q %q", m.Key(), m.Status, m.MetricsReason)
+		}
+	}
+	if gone[0].Name != "job" {
+		t.Errorf("expected the pod without samples first by key, got %s", gone[0].Name)
+	}
+
+	// still listed pods aren't gone
+	if gone := h.departed(later, []metrics.MetricValue{pending}, grace); len(gone) != 1 || gone[0].Name != "web" {
+		t.Errorf("expected only web to be gone, got %v", gone)
+	}
+
+	// past the grace period both are dropped
+	if gone := h.departed(start.Add(2*grace), nil, grace); len(gone) != 0 {
+		t.Errorf("expected nothing past the grace period, got %d", len(gone))
+	}
+	if len(h.data) != 0 {
+		t.Errorf("expected the history to be dropped, got %d objects", len(h.data))
+	}
+}
@@ -18,6 +18,7 @@ type Info struct {
 	Width   int
 	focused bool
 	yaml    string
+	plain   bool
 	conf    config.Colors
 	content viewport.Model
 	style   lipgloss.Style
@@ -56,6 +57,27 @@ func (i Info) View() string {
 
 func (i *Info) SetContent(s string) {
 	i.yaml = s
+	i.plain = false
+	i.setText()
+}
+
+// SetLoading shows a placeholder while the content is being fetched
+func (i *Info) SetLoading() {
+	i.yaml = "Loading..."
+	i.plain = true
+	i.setText()
+}
+
+// SetText shows content that isn't yaml as is
+func (i *Info) SetText(s string) {
+	i.yaml = s
+	i.plain = true
+	i.setText()
+}
+
+func (i *Info) SetError(err error) {
+	i.yaml = "ERROR: " + err.Error()
+	i.plain = true
 	i.setText()
 }
 
@@ -76,6 +98,10 @@ func (i *Info) setText() {
 	i.content.Width = i.Width - h
 	i.content.Height = i.Height - v
 	content := wrap.String(padding.String(i.yaml, uint(i.content.Width)), i.content.Width)
+	if i.plain {
+		i.content.SetContent(content)
+		return
+	}
 	var b bytes.Buffer
 	if err := quick.Highlight(&b, content, "yaml", "terminal256", "friendly"); err == nil {
 		i.content.SetContent(b.String())
@@ -3,6 +3,7 @@ package ui
 import (
 	"fmt"
 	"io"
+	"math"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,7 +14,20 @@ import (
 	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
 )
 
-type listItem string
+// how full a volume is by bytes or inodes before its row is highlighted
+const (
+	volumeWarning  = 80
+	volumeCritical = 90
+)
+
+// the number of objects with unusual usage named under the list
+const maxAnomaliesListed = 3
+
+type listItem struct {
+	key   string
+	line  string
+	color lipgloss.TerminalColor
+}
 
 func (li listItem) FilterValue() string { return "" }
 
@@ -28,17 +42,21 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 		return
 	}
 
-	line := string(i)
+	line := i.line
 	if m.GetOffset() >= len(line) {
 		line = ""
 	} else {
 		line = line[m.GetOffset():]
 		line = utils.Truncate(line, m.Width())
 	}
+	style := Adaptive.Copy()
+	if i.color != nil {
+		style = style.Foreground(i.color)
+	}
 	if index == m.Index() {
-		fmt.Fprintf(w, Adaptive.Copy().Background(lipgloss.Color("245")).Bold(true).Render(line))
+		fmt.Fprint(w, style.Background(lipgloss.Color("245")).Bold(true).Render(line))
 	} else {
-		fmt.Fprintf(w, Adaptive.Copy().Render(line))
+		fmt.Fprint(w, style.Render(line))
 	}
 }
 
@@ -48,12 +66,19 @@ type List struct {
 	focused  bool
 	conf     config.Colors
 	resource metrics.Resource
+	columns  utils.Columns
 	content  list.Model
 	style    lipgloss.Style
 	maxLen   int
+
+	// the object that was selected until it disappeared from the list
+	lost string
+
+	// anomaly is the color of the rows with unusual usage
+	anomaly lipgloss.TerminalColor
 }
 
-func NewList(resource metrics.Resource, conf config.Colors) *List {
+func NewList(resource metrics.Resource, conf config.Colors, columns utils.Columns) *List {
 	itemList := list.New([]list.Item{}, itemDelegate{}, 0, 0)
 	itemList.ItemNamePlural = resource.LowerCase()
 	itemList.Styles.Title = lipgloss.NewStyle().Bold(true).Padding(0)
@@ -61,9 +86,11 @@ func NewList(resource metrics.Resource, conf config.Colors) *List {
 	return &List{
 		resource: resource,
 		conf:     conf,
+		columns:  columns,
 		content:  itemList,
 		focused:  true,
 		style:    Border.Copy().Padding(0, 1),
+		anomaly:  lipgloss.Color(fmt.Sprintf("%d", conf.Anomaly)),
 	}
 }
 
@@ -76,19 +103,84 @@ func (l *List) Update(msg tea.Msg) (List, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		l.content, cmd = l.content.Update(msg)
+		l.lost = ""
 	case tickMsg:
-		header, items := utils.TabStrings(msg.m, l.resource)
+		selected, index := l.GetKey(), l.content.Index()
+		// rows for objects that no longer exist are dimmed at the bottom
+		values := append(append([]metrics.MetricValue{}, msg.m...), msg.gone...)
+		header, items := utils.TabStrings(values, l.resource, l.columns)
 		max := 0
 		listItems := []list.Item{}
-		for _, item := range items {
-			listItems = append(listItems, listItem(item))
+		anomalies := []string{}
+		for i, item := range items {
+			color := rowColor(values[i])
+			if i < len(msg.m) && msg.history != nil {
+				// the health colors are more important than an anomaly
+				if flagged := msg.history.anomaly(values[i].Key()); flagged != "" {
+					anomalies = append(anomalies, fmt.Sprintf("%s (%s)", values[i].Key(), flagged))
+					if color == nil {
+						color = l.anomaly
+					}
+				}
+			}
+			if i >= len(msg.m) {
+				color = Dim
+			}
+			listItems = append(listItems, listItem{key: values[i].Key(), line: item, color: color})
 			if len(item) > max {
 				max = len(item)
 			}
 		}
+		if msg.history != nil {
+			for _, v := range msg.rest {
+				if flagged := msg.history.anomaly(v.Key()); flagged != "" {
+					anomalies = append(anomalies, fmt.Sprintf("%s (%s)", v.Key(), flagged))
+				}
+			}
+		}
 		l.maxLen = max
 		l.content.Title = header
 		l.content.SetItems(listItems)
+
+		// keep the same object selected when the rows get reordered, if it
+		// is gone the cursor stays where it was
+		found := false
+		for i, item := range listItems {
+			if item.(listItem).key == selected {
+				l.content.Select(i)
+				found = true
+				break
+			}
+		}
+		if !found && selected != "" {
+			l.lost = selected
+			if index >= len(listItems) {
+				l.content.Select(len(listItems) - 1)
+			}
+		}
+		if l.content.Index() < 0 {
+			l.content.Select(0)
+		}
+
+		footer := []string{}
+		if l.lost != "" {
+			footer = append(footer, fmt.Sprintf("! %s %s no longer exists", l.resource.LowerCase(), l.lost))
+		}
+		if len(anomalies) > 0 {
+			shown := anomalies
+			if len(shown) > maxAnomaliesListed {
+				shown = shown[:maxAnomaliesListed]
+			}
+			line := fmt.Sprintf("~ unusual usage: %s", strings.Join(shown, ", "))
+			if len(anomalies) > len(shown) {
+				line += fmt.Sprintf(" and %d more", len(anomalies)-len(shown))
+			}
+			footer = append(footer, line)
+		}
+		if msg.hidden > 0 {
+			footer = append(footer, fmt.Sprintf("… %d more %s not shown, narrow the list with --selector or --namespace", msg.hidden, l.resource.LowerCase()))
+		}
+		l.content.SetFooter(strings.Join(footer, "\n"))
 	}
 	return *l, cmd
 }
@@ -114,18 +206,73 @@ func (l *List) SetSize(width, height int) {
 func (l List) GetSelected() string {
 	sections := l.getSections()
 	x := 0
-	if l.resource == metrics.POD {
+	if l.resource != metrics.NODE {
 		x = 1
 	}
+	if len(sections) <= x {
+		return ""
+	}
 	return sections[x]
 }
 
 func (l List) GetNamespace() string {
 	sections := l.getSections()
+	if len(sections) == 0 {
+		return ""
+	}
 	return sections[0]
 }
 
+// GetKey returns the identity of the selected object
+func (l List) GetKey() string {
+	current, ok := l.content.SelectedItem().(listItem)
+	if !ok {
+		return ""
+	}
+	return current.key
+}
+
 func (l List) getSections() []string {
-	current := l.content.SelectedItem().(listItem)
-	return strings.Fields(string(current))
+	current, ok := l.content.SelectedItem().(listItem)
+	if !ok {
+		return nil
+	}
+	return strings.Fields(current.line)
+}
+
+// rowColor highlights unhealthy nodes, nearly full volumes and stuck
+// autoscalers in red and cordoned nodes, filling volumes and maxed out
+// autoscalers in yellow
+func rowColor(m metrics.MetricValue) lipgloss.TerminalColor {
+	if a := m.Autoscaler; a != nil {
+		// autoscalers that can't scale are red and the ones held at their
+		// bounds are yellow
+		if len(a.Unable) > 0 {
+			return Critical
+		}
+		if a.ScalingLimited || (a.MaxReplicas > 0 && a.CurrentReplicas >= a.MaxReplicas) {
+			return Warning
+		}
+		return nil
+	}
+	if m.Volume != nil {
+		full := math.Max(m.Volume.UsedPercent(), m.Volume.InodesPercent())
+		if full >= volumeCritical {
+			return Critical
+		}
+		if full >= volumeWarning {
+			return Warning
+		}
+		return nil
+	}
+	if m.NodeInfo == nil {
+		return nil
+	}
+	if !m.NodeInfo.Healthy() {
+		return Critical
+	}
+	if m.NodeInfo.Unschedulable {
+		return Warning
+	}
+	return nil
 }
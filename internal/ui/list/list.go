@@ -68,6 +68,9 @@ type Model struct {
 	statusMessage      string
 	statusMessageTimer *time.Timer
 
+	// a line shown below the items, ie to summarize rows that were left out
+	footer string
+
 	// The master set of items we're working with.
 	items []Item
 
@@ -142,6 +145,12 @@ func (m *Model) SetItems(i []Item) tea.Cmd {
 	return nil
 }
 
+// SetFooter sets the line rendered below the items, an empty string hides it.
+func (m *Model) SetFooter(s string) {
+	m.footer = s
+	m.updatePagination()
+}
+
 // SetDelegate sets the item delegate.
 func (m *Model) SetDelegate(d ItemDelegate) {
 	m.delegate = d
@@ -171,6 +180,12 @@ func (m Model) Index() int {
 	return m.Paginator.Page*m.Paginator.PerPage + m.cursor
 }
 
+// Select selects the given index of the list and goes to its respective page.
+func (m *Model) Select(index int) {
+	m.Paginator.Page = index / m.Paginator.PerPage
+	m.cursor = index % m.Paginator.PerPage
+}
+
 // Cursor returns the index of the cursor on the current page.
 func (m Model) Cursor() int {
 	return m.cursor
@@ -300,6 +315,9 @@ func (m *Model) updatePagination() {
 	if m.showPagination {
 		availHeight -= lipgloss.Height(m.paginationView())
 	}
+	if m.footer != "" {
+		availHeight -= lipgloss.Height(m.footerView())
+	}
 	availHeight -= lipgloss.Height(m.helpView())
 
 	m.Paginator.PerPage = max(1, availHeight/(m.delegate.Height()+m.delegate.Spacing()))
@@ -405,12 +423,22 @@ func (m Model) View() string {
 		availHeight -= lipgloss.Height(pagination)
 	}
 
+	var footer string
+	if m.footer != "" {
+		footer = m.footerView()
+		availHeight -= lipgloss.Height(footer)
+	}
+
 	help := m.helpView()
 	availHeight -= lipgloss.Height(help)
 
 	content := lipgloss.NewStyle().Height(availHeight).Render(m.populatedView())
 	sections = append(sections, content)
 
+	if m.footer != "" {
+		sections = append(sections, footer)
+	}
+
 	if m.showPagination {
 		sections = append(sections, pagination)
 	}
@@ -465,6 +493,14 @@ func (m Model) paginationView() string {
 	return style.Render(s)
 }
 
+func (m Model) footerView() string {
+	lines := strings.Split(m.footer, "\n")
+	for i := range lines {
+		lines[i] = utils.Truncate(lines[i], m.width)
+	}
+	return m.Styles.Footer.Render(strings.Join(lines, "\n"))
+}
+
 func (m Model) helpView() string {
 	return m.Styles.HelpStyle.Render(m.Help.View(m))
 }
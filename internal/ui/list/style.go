@@ -16,6 +16,7 @@ type Styles struct {
 	Title    lipgloss.Style
 
 	NoItems lipgloss.Style
+	Footer  lipgloss.Style
 
 	PaginationStyle lipgloss.Style
 	HelpStyle       lipgloss.Style
@@ -43,6 +44,9 @@ func DefaultStyles() (s Styles) {
 	s.NoItems = lipgloss.NewStyle().
 		Foreground(lipgloss.AdaptiveColor{Light: "#909090", Dark: "#626262"})
 
+	s.Footer = lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{Light: "#909090", Dark: "#626262"})
+
 	s.ArabicPagination = lipgloss.NewStyle().Foreground(subduedColor)
 
 	s.PaginationStyle = lipgloss.NewStyle().PaddingLeft(2) //nolint:gomnd
@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"k8s.io/cli-runtime/pkg/printers"
+)
+
+const (
+	// growth is measured over the readings taken in this window
+	neighborWindow = 5 * time.Minute
+
+	// the number of pods in each ranking
+	neighborRows = 10
+
+	// a reading is only started once the selection has stayed on a node
+	// this long so scrolling through the list doesn't read every node
+	neighborsDebounce = 300 * time.Millisecond
+)
+
+// neighborsMsg has a reading of the usage of a node and its pods
+type neighborsMsg struct {
+	usage metrics.NodeUsage
+	node  string
+	seq   int
+	err   error
+}
+
+// neighborsSelectMsg is sent after the selection changed, seq is the
+// change it was sent for
+type neighborsSelectMsg struct {
+	seq int
+}
+
+// neighborsCmd reads the usage of the pods on the node, it is sent on every
+// refresh for the selected node so the growth is already known when the
+// report is opened. Only one reading is in flight at a time, the one for
+// another node is cancelled.
+func (a *App) neighborsCmd(node string) tea.Cmd {
+	if node == "" {
+		return nil
+	}
+	if a.neighborsCancel != nil && a.neighborsReading == node {
+		return nil
+	}
+	a.cancelNeighbors()
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	a.neighborsCancel, a.neighborsReading = cancel, node
+	seq := a.neighborsSeq
+	return func() tea.Msg {
+		defer cancel()
+		usage, err := a.client.GetNodeUsage(ctx, node)
+		return neighborsMsg{usage: usage, node: node, seq: seq, err: err}
+	}
+}
+
+// cancelNeighbors stops the reading in flight so its reply is dropped
+func (a *App) cancelNeighbors() {
+	if a.neighborsCancel == nil {
+		return
+	}
+	a.neighborsCancel()
+	a.neighborsCancel = nil
+	a.neighborsSeq++
+}
+
+// neighborsSelect is called when the selection changes, the reading of the
+// previous node is stopped and the new one is read after the debounce
+func (a *App) neighborsSelect() tea.Cmd {
+	if a.neighbors == nil {
+		return nil
+	}
+	if a.neighborsReading != a.neighborsTarget() {
+		a.cancelNeighbors()
+	}
+	a.neighborsSelected++
+	seq := a.neighborsSelected
+	return tea.Tick(neighborsDebounce, func(time.Time) tea.Msg {
+		return neighborsSelectMsg{seq: seq}
+	})
+}
+
+// neighborsTarget returns the node whose pods are read, the one the report
+// is open for or else the selected one
+func (a *App) neighborsTarget() string {
+	if a.neighbors == nil {
+		return ""
+	}
+	if a.neighborsNode != "" {
+		return a.neighborsNode
+	}
+	return a.current
+}
+
+// showNeighbors records the reading and shows the report if it is still
+// open for the node. Failing to read it is shown in the status bar since
+// the readings are mostly taken while the report is closed.
+func (a *App) showNeighbors(msg neighborsMsg) {
+	if msg.seq != a.neighborsSeq {
+		return
+	}
+	a.neighborsCancel = nil
+	a.statusBar.Warn("node usage", msg.err)
+	if msg.err == nil {
+		a.neighbors.Add(msg.usage)
+	}
+	if a.neighborsNode != msg.node || a.itemsPane.focused {
+		return
+	}
+	if msg.err != nil {
+		a.infoPane.SetError(msg.err)
+		return
+	}
+	if r, ok := a.neighbors.Report(msg.node); ok {
+		a.infoPane.SetText(neighborsText(r))
+	}
+}
+
+// neighborsText renders the pods of a node by their share of its usage and
+// by their growth. The ones that make up most of the growth are red.
+func neighborsText(r metrics.NeighborReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: cpu %dm, memory %dMi\n", r.Node, r.CPU, r.Mem)
+	if r.Since > 0 {
+		fmt.Fprintf(&b, "growth over the last %s: cpu %+dm, memory %+dMi\n", r.Since.Round(time.Second), r.CPUGrowth, r.MemGrowth)
+	} else {
+		b.WriteString("growth shows up after the next refresh\n")
+	}
+	b.WriteString("\nBY SHARE OF NODE USAGE\n")
+	b.WriteString(neighborTable(r.ByShare, r.Since > 0))
+	if r.Since > 0 {
+		b.WriteString("\nBY GROWTH\n")
+		b.WriteString(neighborTable(r.ByGrowth, true))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func neighborTable(pods []metrics.Neighbor, growth bool) string {
+	if len(pods) > neighborRows {
+		pods = pods[:neighborRows]
+	}
+	var b bytes.Buffer
+	w := printers.GetNewTabWriter(&b)
+	fmt.Fprint(w, "POD\tCPU\tCPU%\tMEM\tMEM%")
+	if growth {
+		fmt.Fprint(w, "\tCPU GROWTH\tMEM GROWTH")
+	}
+	fmt.Fprintln(w)
+	for _, p := range pods {
+		fmt.Fprintf(w, "%s/%s\t%dm\t%.1f%%\t%dMi\t%.1f%%", p.Namespace, p.Name, p.CPU, p.CPUShare, p.Mem, p.MemShare)
+		if growth {
+			fmt.Fprintf(w, "\t%+dm\t%+dMi", p.CPUGrowth, p.MemGrowth)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	// the rows are colored after the tabwriter so the escape codes don't
+	// throw off the alignment
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	for i, p := range pods {
+		if growth && p.Culprit {
+			lines[i+1] = Adaptive.Copy().Foreground(Critical).Render(lines[i+1])
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
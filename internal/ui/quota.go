@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+)
+
+// the width of the quota progress bars in cells
+const quotaBarWidth = 20
+
+// quotaText renders the quota report with a progress bar for every
+// resource. Exhausted resources are red and the ones above 90% are yellow
+// since that is usually why new pods aren't showing up.
+func quotaText(r metrics.QuotaReport) string {
+	var b strings.Builder
+	if len(r.Quotas) == 0 {
+		fmt.Fprintf(&b, "no resource quotas in %s\n", r.Namespace)
+	}
+	for _, q := range r.Quotas {
+		fmt.Fprintf(&b, "QUOTA %s", q.Name)
+		if len(q.Scopes) > 0 {
+			fmt.Fprintf(&b, " (scopes: %s)", strings.Join(q.Scopes, ", "))
+		}
+		b.WriteString("\n")
+		width := 0
+		for _, res := range q.Resources {
+			if len(res.Name) > width {
+				width = len(res.Name)
+			}
+		}
+		for _, res := range q.Resources {
+			line := fmt.Sprintf("  %-*s %s %4.0f%% %s/%s", width, res.Name, quotaBar(res.Fraction), res.Fraction*100, res.Used, res.Hard)
+			switch {
+			case res.Fraction >= 1:
+				line = Adaptive.Copy().Foreground(Critical).Render(line)
+			case res.Fraction >= 0.9:
+				line = Adaptive.Copy().Foreground(Warning).Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.LimitRanges) > 0 {
+		b.WriteString("LIMITRANGE DEFAULTS\n")
+		for _, lr := range r.LimitRanges {
+			fmt.Fprintf(&b, "  %s\n", lr.Name)
+			if lr.DefaultRequest != "" {
+				fmt.Fprintf(&b, "    requests: %s\n", lr.DefaultRequest)
+			}
+			if lr.Default != "" {
+				fmt.Fprintf(&b, "    limits: %s\n", lr.Default)
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Defaulted) > 0 {
+		b.WriteString("PODS USING LIMITRANGE DEFAULTS\n")
+		for _, pod := range r.Defaulted {
+			fmt.Fprintf(&b, "  %s: %s\n", pod.Name, pod.Applied)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func quotaBar(fraction float64) string {
+	filled := int(fraction*quotaBarWidth + 0.5)
+	if filled > quotaBarWidth {
+		filled = quotaBarWidth
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", quotaBarWidth-filled) + "]"
+}
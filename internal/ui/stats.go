@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"k8s.io/cli-runtime/pkg/printers"
+)
+
+// seriesStats summarize the readings of one line of an object's graphs.
+// The rates are per minute, over the whole history and since the reading
+// before the current one.
+type seriesStats struct {
+	min, max, mean   float64
+	p50, p95, p99    float64
+	current          float64
+	rate, recentRate float64
+}
+
+func computeStats(values []float64, times []time.Time) seriesStats {
+	if len(values) == 0 {
+		return seriesStats{}
+	}
+	s := seriesStats{current: values[len(values)-1]}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	s.min, s.max = sorted[0], sorted[len(sorted)-1]
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	s.mean = sum / float64(len(values))
+	s.p50, s.p95, s.p99 = percentile(sorted, 50), percentile(sorted, 95), percentile(sorted, 99)
+	rate := func(i, j int) float64 {
+		minutes := times[j].Sub(times[i]).Minutes()
+		if minutes <= 0 {
+			return 0
+		}
+		return (values[j] - values[i]) / minutes
+	}
+	if last := len(values) - 1; last > 0 {
+		s.rate = rate(0, last)
+		s.recentRate = rate(last-1, last)
+	}
+	return s
+}
+
+// percentile returns the nearest rank percentile of the sorted values, it
+// is 0 without any
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// statsNames are the names and units of the two lines that are summarized
+// for the object, they are the same ones the graphs show
+func (g *Graphs) statsNames(key string) ([2]string, [2]string) {
+	switch g.resource {
+	case metrics.PVC:
+		return [2]string{"USED", "INODES"}, [2]string{"Mi", ""}
+	case metrics.HPA:
+		scaledOn, ok := g.scaledOn[key]
+		if !ok {
+			scaledOn = "METRIC"
+		}
+		return [2]string{"REPLICAS", scaledOn}, [2]string{"", ""}
+	}
+	return [2]string{"CPU", "MEMORY"}, [2]string{"m", "Mi"}
+}
+
+// statsText renders the statistics of the usage of the object over the
+// history that is kept for it
+func statsText(key string, h *history, names, units [2]string) string {
+	s, ok := h.data[key]
+	if !ok || len(s.samples) == 0 {
+		return fmt.Sprintf("no readings of %s yet", key)
+	}
+	times := make([]time.Time, len(s.samples))
+	lines := [2][]float64{}
+	for i, p := range s.samples {
+		times[i] = p.at
+		lines[0] = append(lines[0], p.cpu)
+		lines[1] = append(lines[1], p.mem)
+	}
+	stats := [2]seriesStats{computeStats(lines[0], times), computeStats(lines[1], times)}
+
+	var b bytes.Buffer
+	span := times[len(times)-1].Sub(times[0]).Round(time.Second)
+	fmt.Fprintf(&b, "%s over %s (%d readings)\n\n", key, span, len(times))
+	w := printers.GetNewTabWriter(&b)
+	fmt.Fprintf(w, "\t%s\t%s\n", names[0], names[1])
+	row := func(label string, value func(seriesStats) float64, format string) {
+		fmt.Fprintf(w, "%s\t"+format+"%s\t"+format+"%s\n", label, value(stats[0]), units[0], value(stats[1]), units[1])
+	}
+	row("current", func(s seriesStats) float64 { return s.current }, "%.1f")
+	row("min", func(s seriesStats) float64 { return s.min }, "%.1f")
+	row("max", func(s seriesStats) float64 { return s.max }, "%.1f")
+	row("mean", func(s seriesStats) float64 { return s.mean }, "%.1f")
+	row("p50", func(s seriesStats) float64 { return s.p50 }, "%.1f")
+	row("p95", func(s seriesStats) float64 { return s.p95 }, "%.1f")
+	row("p99", func(s seriesStats) float64 { return s.p99 }, "%.1f")
+	units[0] += "/min"
+	units[1] += "/min"
+	row("rate", func(s seriesStats) float64 { return s.rate }, "%+.1f")
+	row("rate (last)", func(s seriesStats) float64 { return s.recentRate }, "%+.1f")
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// showStats shows the statistics of the object they were opened for
+func (a *App) showStats() {
+	names, units := a.graphsPane.statsNames(a.statsKey)
+	a.infoPane.SetText(statsText(a.statsKey, a.history, names, units))
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{name: "empty", sorted: nil, p: 50, want: 0},
+		{name: "single p50", sorted: []float64{7}, p: 50, want: 7},
+		{name: "single p99", sorted: []float64{7}, p: 99, want: 7},
+		{name: "even p50 is the lower middle", sorted: []float64{1, 2, 3, 4}, p: 50, want: 2},
+		{name: "even p95", sorted: []float64{1, 2, 3, 4}, p: 95, want: 4},
+		{name: "p0 is the min", sorted: []float64{1, 2, 3, 4}, p: 0, want: 1},
+		{name: "p100 is the max", sorted: []float64{1, 2, 3, 4}, p: 100, want: 4},
+		{name: "ten p50", sorted: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, p: 50, want: 5},
+		{name: "ten p95", sorted: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, p: 95, want: 10},
+		{name: "odd p50 is the middle", sorted: []float64{1, 5, 9}, p: 50, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	start := time.Now()
+	minutes := func(n ...int) []time.Time {
+		times := []time.Time{}
+		for _, m := range n {
+			times = append(times, start.Add(time.Duration(m)*time.Minute))
+		}
+		return times
+	}
+	tests := []struct {
+		name   string
+		values []float64
+		times  []time.Time
+		want   seriesStats
+	}{
+		{
+			name: "empty",
+			want: seriesStats{},
+		},
+		{
+			name:   "single reading has no rate",
+			values: []float64{42},
+			times:  minutes(0),
+			want:   seriesStats{min: 42, max: 42, mean: 42, p50: 42, p95: 42, p99: 42, current: 42},
+		},
+		{
+			name:   "rates per minute",
+			values: []float64{10, 30, 20},
+			times:  minutes(0, 1, 2),
+			want:   seriesStats{min: 10, max: 30, mean: 20, p50: 20, p95: 30, p99: 30, current: 20, rate: 5, recentRate: -10},
+		},
+		{
+			name:   "readings at the same time have no rate",
+			values: []float64{10, 20},
+			times:  minutes(1, 1),
+			want:   seriesStats{min: 10, max: 20, mean: 15, p50: 10, p95: 20, p99: 20, current: 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeStats(tt.values, tt.times); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
+)
+
+// StatusBar is the single line at the bottom of the app showing where
+// the data comes from and how fresh it is
+type StatusBar struct {
+	Width     int
+	context   string
+	namespace string
+	// scope says what part of the namespace is shown when it isn't all of it
+	scope       string
+	lastRefresh time.Time
+	latency     time.Duration
+	failures    int
+	retryIn     time.Duration
+	lastErr     error
+	refreshing  bool
+	window      time.Duration
+	cadence     time.Duration
+	poll        time.Duration
+	// cost is the total of the pods when pricing is configured
+	cost *metrics.Cost
+	// warnings are the errors getting optional data by what it was for,
+	// they don't stop the refreshes
+	warnings map[string]error
+}
+
+func NewStatusBar(context, namespace string) *StatusBar {
+	if namespace == "" {
+		namespace = "all"
+	}
+	return &StatusBar{
+		context:   context,
+		namespace: namespace,
+	}
+}
+
+func (s *StatusBar) SetSize(width int) {
+	s.Width = width
+}
+
+// Success records a successful refresh
+func (s *StatusBar) Success(t time.Time, latency time.Duration) {
+	s.lastRefresh = t
+	s.latency = latency
+	s.failures = 0
+	s.retryIn = 0
+	s.lastErr = nil
+}
+
+// SetCadence records the metrics window, how often new samples have been
+// showing up and how often they are polled for
+func (s *StatusBar) SetCadence(window, cadence, poll time.Duration) {
+	s.window = window
+	s.cadence = cadence
+	s.poll = poll
+}
+
+// Failure records a failed refresh and when the next attempt will be made
+func (s *StatusBar) Failure(err error, failures int, retryIn time.Duration) {
+	s.lastErr = err
+	s.failures = failures
+	s.retryIn = retryIn
+}
+
+// Warn shows the error for the optional data until it is called again with
+// a nil error
+func (s *StatusBar) Warn(name string, err error) {
+	if err == nil {
+		delete(s.warnings, name)
+		return
+	}
+	if s.warnings == nil {
+		s.warnings = map[string]error{}
+	}
+	s.warnings[name] = err
+}
+
+func (s StatusBar) View() string {
+	sections := []string{
+		fmt.Sprintf("context: %s", s.context),
+		fmt.Sprintf("namespace: %s", s.namespace),
+	}
+	if s.scope != "" {
+		sections = append(sections, s.scope)
+	}
+	if !s.lastRefresh.IsZero() {
+		sections = append(sections,
+			fmt.Sprintf("last refresh: %s", s.lastRefresh.Format("15:04:05")),
+			fmt.Sprintf("latency: %s", s.latency.Round(time.Millisecond)),
+		)
+	}
+	if s.window > 0 {
+		sections = append(sections, fmt.Sprintf("window: %s", s.window))
+	}
+	if s.cadence > 0 {
+		sections = append(sections, fmt.Sprintf("scraped every %s", s.cadence.Round(time.Second)))
+	}
+	if s.poll > 0 {
+		sections = append(sections, fmt.Sprintf("polling every %s", s.poll.Round(100*time.Millisecond)))
+	}
+	if s.cost != nil {
+		sections = append(sections, fmt.Sprintf("cost: $%.2f/h requested, $%.2f/h used", s.cost.Requested, s.cost.Used))
+	}
+	if s.refreshing {
+		sections = append(sections, "refreshing...")
+	}
+	style := Adaptive.Copy()
+	if len(s.warnings) > 0 {
+		style = style.Foreground(Warning)
+		names := make([]string, 0, len(s.warnings))
+		for name := range s.warnings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sections = append(sections, fmt.Sprintf("%s: %s", name, s.warnings[name].Error()))
+		}
+	}
+	if s.lastErr != nil {
+		style = style.Foreground(Critical)
+		sections = append(sections, fmt.Sprintf("failures: %d, retrying in %s: %s", s.failures, s.retryIn, s.lastErr.Error()))
+	}
+	line := strings.ReplaceAll(strings.Join(sections, " | "), "\n", " ")
+	return style.Render(lipgloss.NewStyle().Padding(0, 1).Render(utils.Truncate(line, s.Width-2)))
+}
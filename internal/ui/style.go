@@ -27,4 +27,8 @@ var (
 	Adaptive = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "0", Dark: "15"})
 	Border   = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder())
 	ErrStyle = lipgloss.NewStyle().BorderStyle(lipgloss.DoubleBorder()).BorderForeground(lipgloss.Color("9"))
+
+	Critical = lipgloss.Color("9")
+	Warning  = lipgloss.Color("11")
+	Dim      = lipgloss.AdaptiveColor{Light: "250", Dark: "240"}
 )
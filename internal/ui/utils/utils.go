@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/chriskim06/kubectl-topui/internal/metrics"
@@ -11,27 +14,212 @@ import (
 	"k8s.io/cli-runtime/pkg/printers"
 )
 
-const HelpText = `This app shows metrics for pods and nodes! The graphs display the limit and usage for the cpu and memory of whichever item is selected.
+const HelpText = `This app shows metrics for pods, nodes, persistent volume claims and horizontal pod autoscalers! The graphs display the limit and usage for the cpu and memory of whichever item is selected, the used bytes and inodes of a claim or the replicas and first metric of an autoscaler.
 
 Keyboard Shortcuts
   - j: move selection down or scroll down spec
   - k: move selection up or scroll up spec
   - q: quit application or clear pod/node spec
+  - d: simulate draining the selected node
+  - p: show the pods scaled by the selected hpa, q goes back
+  - c: show the estimated cost by namespace and workload (when pricing is configured)
+  - t: show min, max, mean, percentiles and rate of change of the selected item's graphs
+  - n: rank the pods on the selected node by their share of its usage and their growth
+  - w: show the pods, workloads and namespaces using the least of their requests
+  - r: show resource quota usage and limit range defaults of the namespace
+  - s: switch the graphs between cpu/memory, network/storage (with --stats) and custom metrics
   - ?: open/close this help menu`
 
 var (
 	headers = map[metrics.Resource]string{
-		metrics.POD:  "NAMESPACE\tNAME\tREADY\tSTATUS\tNODE\tCPU USAGE\tCPU LIMIT\tMEM USAGE\tMEM LIMIT\tRESTARTS\tAGE",
+		metrics.POD:  "NAMESPACE\tNAME\tREADY\tSTATUS\tNODE\tCPU USAGE\tCPU LIMIT\tMEM USAGE\tMEM LIMIT\tRESTARTS\tLAST TERMINATION\tAGE",
+		metrics.PVC:  "NAMESPACE\tNAME\tSTATUS\tSTORAGECLASS\tCAPACITY\tUSED\tUSED%\tINODES%\tPODS\tAGE",
+		metrics.HPA:  "NAMESPACE\tNAME\tREFERENCE\tTARGETS\tMINPODS\tMAXPODS\tREPLICAS\tDESIRED\tAGE",
 		metrics.NODE: "NAME\tCPU USAGE\tCPU AVAILABLE\tCPU PERCENT\tMEM USAGE\tMEM AVAILABLE\tMEM PERCENT",
 	}
+
+	// optional columns that can be appended to the default ones
+	columns = map[metrics.Resource]map[string]column{
+		metrics.POD: {
+			"network": networkColumn,
+			"workload": {"WORKLOAD", func(m metrics.MetricValue) string {
+				return m.Workload
+			}},
+			"cost": {"COST/H REQ/USED", func(m metrics.MetricValue) string {
+				return FormatCost(m, m.Cost, "%.3f")
+			}},
+			"cost-monthly": {"COST/MO REQ/USED", func(m metrics.MetricValue) string {
+				if m.Cost == nil {
+					return "-"
+				}
+				monthly := m.Cost.Monthly()
+				return FormatCost(m, &monthly, "%.2f")
+			}},
+			"defaults": {"LIMITRANGE DEFAULTS", func(m metrics.MetricValue) string {
+				return orNone(m.LimitRangeDefaults)
+			}},
+			"throttling": {"THROTTLED", func(m metrics.MetricValue) string {
+				if m.Throttling == nil {
+					return "-"
+				}
+				return fmt.Sprintf("%.1f%%", m.Throttling.Percent())
+			}},
+			"ephemeral": {"EPHEMERAL", func(m metrics.MetricValue) string {
+				if m.Stats == nil {
+					return "-"
+				}
+				if m.EphemeralLimit == 0 {
+					return FormatBytes(float64(m.Stats.Ephemeral))
+				}
+				return fmt.Sprintf("%s/%s", FormatBytes(float64(m.Stats.Ephemeral)), FormatBytes(float64(m.EphemeralLimit)))
+			}},
+			"rootfs": {"ROOTFS", func(m metrics.MetricValue) string {
+				if m.Stats == nil {
+					return "-"
+				}
+				return FormatBytes(float64(m.Stats.Rootfs))
+			}},
+		},
+		metrics.NODE: {
+			"network": networkColumn,
+			"ephemeral": {"EPHEMERAL", func(m metrics.MetricValue) string {
+				if m.Stats == nil {
+					return "-"
+				}
+				return fmt.Sprintf("%s/%s", FormatBytes(float64(m.Stats.Ephemeral)), FormatBytes(float64(m.Stats.EphemeralCapacity)))
+			}},
+			"rootfs": {"ROOTFS", func(m metrics.MetricValue) string {
+				if m.Stats == nil {
+					return "-"
+				}
+				return fmt.Sprintf("%s/%s", FormatBytes(float64(m.Stats.Rootfs)), FormatBytes(float64(m.Stats.RootfsCapacity)))
+			}},
+			"status": {"STATUS", func(m metrics.MetricValue) string {
+				if m.NodeInfo.Unschedulable {
+					return m.NodeInfo.Status + ",SchedulingDisabled"
+				}
+				return m.NodeInfo.Status
+			}},
+			"pressure": {"PRESSURE", func(m metrics.MetricValue) string {
+				if len(m.NodeInfo.Pressure) == 0 {
+					return "<none>"
+				}
+				return strings.Join(m.NodeInfo.Pressure, ",")
+			}},
+			"taints": {"TAINTS", func(m metrics.MetricValue) string {
+				return fmt.Sprintf("%d", m.NodeInfo.Taints)
+			}},
+			"version": {"VERSION", func(m metrics.MetricValue) string {
+				return orNone(m.NodeInfo.KubeletVersion)
+			}},
+			"os": {"OS/ARCH", func(m metrics.MetricValue) string {
+				return fmt.Sprintf("%s/%s", m.NodeInfo.OS, m.NodeInfo.Arch)
+			}},
+			"instance-type": {"INSTANCE TYPE", func(m metrics.MetricValue) string {
+				return orNone(m.NodeInfo.InstanceType)
+			}},
+			"zone": {"ZONE", func(m metrics.MetricValue) string {
+				return orNone(m.NodeInfo.Zone)
+			}},
+		},
+	}
 )
 
-func TabStrings(data []metrics.MetricValue, resource metrics.Resource) (string, []string) {
+type column struct {
+	header string
+	value  func(metrics.MetricValue) string
+}
+
+var networkColumn = column{"NET RX/TX", func(m metrics.MetricValue) string {
+	if m.Stats == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s/s %s/s", FormatBytes(m.Stats.RxRate), FormatBytes(m.Stats.TxRate))
+}}
+
+// statsColumns need the kubelet summary api
+var statsColumns = map[string]bool{"network": true, "ephemeral": true, "rootfs": true}
+
+// NeedsStats returns whether any of the columns need the summary api
+func NeedsStats(names []string) bool {
+	for _, name := range names {
+		if statsColumns[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Columns are the optional columns shown after the default ones
+type Columns []column
+
+// NewColumns returns the requested built in columns followed by one for
+// each custom metric. The custom metrics are validated to not reuse the
+// names of the built in columns.
+func NewColumns(resource metrics.Resource, requested []string, custom []string) Columns {
+	shown := Columns{}
+	for _, name := range requested {
+		if c, ok := columns[resource][name]; ok {
+			shown = append(shown, c)
+		}
+	}
+	for _, name := range custom {
+		name := name
+		shown = append(shown, column{strings.ToUpper(name), func(m metrics.MetricValue) string {
+			v, ok := m.Custom[name]
+			if !ok {
+				return "-"
+			}
+			return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+		}})
+	}
+	return shown
+}
+
+// NeedsThrottling returns whether any of the columns need cadvisor
+func NeedsThrottling(names []string) bool {
+	for _, name := range names {
+		if name == "throttling" {
+			return true
+		}
+	}
+	return false
+}
+
+// ColumnNames returns the optional columns available for a resource
+func ColumnNames(resource metrics.Resource) []string {
+	names := []string{}
+	for name := range columns[resource] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateColumns returns an error if any of the given columns aren't
+// available for the resource
+func ValidateColumns(resource metrics.Resource, names []string) error {
+	for _, name := range names {
+		if _, ok := columns[resource][name]; !ok {
+			return fmt.Errorf("invalid column %q provided, must be one of: %s", name, strings.Join(ColumnNames(resource), ", "))
+		}
+	}
+	return nil
+}
+
+func TabStrings(data []metrics.MetricValue, resource metrics.Resource, extra Columns) (string, []string) {
 	var b bytes.Buffer
 	w := printers.GetNewTabWriter(&b)
-	fmt.Fprintln(w, headers[resource])
+	fmt.Fprint(w, headers[resource])
+	for _, c := range extra {
+		fmt.Fprintf(w, "\t%s", c.header)
+	}
+	fmt.Fprintln(w)
 	for i, m := range data {
 		writeMetric(w, m, resource)
+		for _, c := range extra {
+			fmt.Fprintf(w, "\t%s", c.value(m))
+		}
 		if i != len(data)-1 {
 			fmt.Fprint(w, "\n")
 		}
@@ -50,23 +238,143 @@ func writeMetric(w io.Writer, m metrics.MetricValue, resource metrics.Resource)
 		fmt.Fprintf(w, "%s\t", fmt.Sprintf("%d/%d", m.Ready, m.Total))
 		fmt.Fprintf(w, "%v\t", m.Status)
 		fmt.Fprintf(w, "%v\t", m.Node)
-		fmt.Fprintf(w, "%vm\t", m.CPUCores.MilliValue())
+		if m.MetricsReason != "" {
+			fmt.Fprintf(w, "<%s>\t", m.MetricsReason)
+		} else {
+			fmt.Fprintf(w, "%vm\t", m.CPUCores.MilliValue())
+		}
 		fmt.Fprintf(w, "%vm\t", m.CPULimit.MilliValue())
-		fmt.Fprintf(w, "%vMi\t", m.MemCores)
+		if m.MetricsReason != "" {
+			fmt.Fprint(w, "-\t")
+		} else {
+			fmt.Fprintf(w, "%vMi\t", m.MemCores)
+		}
 		fmt.Fprintf(w, "%vMi\t", m.MemLimit)
 		fmt.Fprintf(w, "%v\t", m.Restarts)
+		fmt.Fprintf(w, "%v\t", orNone(m.LastTermination))
 		fmt.Fprintf(w, "%v", m.Age)
+	} else if resource == metrics.HPA {
+		a := m.Autoscaler
+		targets := []string{}
+		for _, metric := range a.Metrics {
+			targets = append(targets, fmt.Sprintf("%s:%s/%s", metric.Name, metric.Current, metric.Target))
+		}
+		fmt.Fprintf(w, "%v\t", m.Namespace)
+		fmt.Fprintf(w, "%v\t", m.Name)
+		fmt.Fprintf(w, "%v\t", a.Reference)
+		fmt.Fprintf(w, "%v\t", orNone(strings.Join(targets, ",")))
+		fmt.Fprintf(w, "%v\t", a.MinReplicas)
+		fmt.Fprintf(w, "%v\t", a.MaxReplicas)
+		fmt.Fprintf(w, "%v\t", a.CurrentReplicas)
+		fmt.Fprintf(w, "%v\t", a.DesiredReplicas)
+		fmt.Fprintf(w, "%v", m.Age)
+	} else if resource == metrics.PVC {
+		v := m.Volume
+		fmt.Fprintf(w, "%v\t", m.Namespace)
+		fmt.Fprintf(w, "%v\t", m.Name)
+		fmt.Fprintf(w, "%v\t", m.Status)
+		fmt.Fprintf(w, "%v\t", orNone(v.StorageClass))
+		fmt.Fprintf(w, "%v\t", FormatBytes(float64(v.Capacity)))
+		if m.MetricsReason != "" {
+			fmt.Fprintf(w, "<%s>\t-\t-\t", m.MetricsReason)
+		} else {
+			fmt.Fprintf(w, "%v\t", FormatBytes(float64(v.Used)))
+			fmt.Fprintf(w, "%.2f%%\t", v.UsedPercent())
+			fmt.Fprintf(w, "%.2f%%\t", v.InodesPercent())
+		}
+		fmt.Fprintf(w, "%v\t", orNone(strings.Join(v.Pods, ",")))
+		fmt.Fprintf(w, "%v", m.Age)
+	} else if m.MetricsReason != "" {
+		fmt.Fprintf(w, "%v\t", m.Name)
+		fmt.Fprintf(w, "<%s>\t", m.MetricsReason)
+		fmt.Fprintf(w, "%vm\t", m.CPULimit.MilliValue())
+		fmt.Fprint(w, "-\t")
+		fmt.Fprint(w, " -\t")
+		fmt.Fprintf(w, " %vMi\t", m.MemLimit)
+		fmt.Fprint(w, " -")
 	} else {
 		fmt.Fprintf(w, "%v\t", m.Name)
 		fmt.Fprintf(w, "%vm\t", m.CPUCores.MilliValue())
 		fmt.Fprintf(w, "%vm\t", m.CPULimit.MilliValue())
-		fmt.Fprintf(w, "%.2f", m.CPUPercent)
-		w.Write([]byte("%%\t"))
+		fmt.Fprintf(w, "%.2f%%\t", m.CPUPercent)
 		fmt.Fprintf(w, " %vMi\t", m.MemCores)
 		fmt.Fprintf(w, " %vMi\t", m.MemLimit)
-		fmt.Fprintf(w, " %.2f", m.MemPercent)
-		w.Write([]byte("%%"))
+		fmt.Fprintf(w, " %.2f%%", m.MemPercent)
+	}
+}
+
+// WriteWasteReport writes the namespaces, workloads and pods of the waste
+// report as tables, each one is cut off after limit rows when it is above 0
+func WriteWasteReport(out io.Writer, r metrics.WasteReport, limit int) {
+	w := printers.GetNewTabWriter(out)
+	sections := []struct {
+		title string
+		rows  []metrics.Waste
+	}{
+		{"NAMESPACE", r.Namespaces},
+		{"WORKLOAD", r.Workloads},
+		{"POD", r.Pods},
+	}
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\tCPU REQ\tCPU AVG\tCPU PEAK\tCPU UNUSED\tMEM REQ\tMEM AVG\tMEM PEAK\tMEM UNUSED\tSAMPLES\n", section.title)
+		rows := section.rows
+		if limit > 0 && len(rows) > limit {
+			rows = rows[:limit]
+		}
+		for _, row := range rows {
+			name := row.Namespace
+			if row.Name != "" {
+				name += "/" + row.Name
+			}
+			fmt.Fprintf(w, "%s\t%dm\t%dm\t%dm\t%dm\t%dMi\t%dMi\t%dMi\t%dMi\t%d\n", name,
+				row.CPURequest, row.CPUAverage, row.CPUPeak, row.CPUUnused,
+				row.MemRequest, row.MemAverage, row.MemPeak, row.MemUnused, row.Samples)
+		}
+		if len(rows) < len(section.rows) {
+			fmt.Fprintf(w, "... %d more\n", len(section.rows)-len(rows))
+		}
+	}
+	if r.FailedPolls > 0 {
+		fmt.Fprintf(w, "\n%d polls failed and are missing from the samples\n", r.FailedPolls)
+	}
+	w.Flush()
+}
+
+// FormatBytes formats a number of bytes with a binary suffix, ie 1.5Mi
+func FormatBytes(b float64) string {
+	units := []string{"", "Ki", "Mi", "Gi", "Ti", "Pi"}
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f", b)
+	}
+	return fmt.Sprintf("%.1f%s", b, units[i])
+}
+
+// FormatCost formats the requested and used cost with the given verb, the
+// used part is left out when there is no usage for the object
+func FormatCost(m metrics.MetricValue, c *metrics.Cost, verb string) string {
+	if c == nil {
+		return "-"
+	}
+	requested := fmt.Sprintf("$"+verb, c.Requested)
+	if m.MetricsReason != "" {
+		return requested + "/-"
+	}
+	return fmt.Sprintf("%s/$"+verb, requested, c.Used)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
 	}
+	return s
 }
 
 func Truncate(s string, width int) string {
@@ -0,0 +1,23 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/chriskim06/kubectl-topui/internal/metrics"
+	"github.com/chriskim06/kubectl-topui/internal/ui/utils"
+)
+
+// the number of rows of each table in the waste report
+const wasteRows = 20
+
+// wasteText renders the waste report of the pods seen since the app started
+func wasteText(r metrics.WasteReport) string {
+	if len(r.Pods) == 0 {
+		return "no pods with requests have had usage reported yet"
+	}
+	var b bytes.Buffer
+	b.WriteString("unused is the request minus the peak usage seen since starting\n\n")
+	utils.WriteWasteReport(&b, r, wasteRows)
+	return strings.TrimRight(b.String(), "\n")
+}